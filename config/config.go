@@ -41,7 +41,27 @@ const (
 	EnvBotToken          = "BOT_TOKEN"
 	EnvCobaltAPI         = "COBALT_API"
 	EnvCobaltAPIKey      = "COBALT_API_KEY"
+	EnvCobaltInstances   = "COBALT_INSTANCES"
+	EnvCobaltVideoCodec  = "COBALT_YOUTUBE_VIDEO_CODEC"
+	EnvCobaltAudioFormat = "COBALT_AUDIO_FORMAT"
+	EnvCobaltAudioRate   = "COBALT_AUDIO_BITRATE"
+	EnvCobaltFilename    = "COBALT_FILENAME_STYLE"
+	EnvCobaltTikTokAudio = "COBALT_TIKTOK_FULL_AUDIO"
+	EnvCobaltAlwaysProxy = "COBALT_ALWAYS_PROXY"
+	EnvCobaltNoMetadata  = "COBALT_DISABLE_METADATA"
+	EnvCobaltTwitterGif  = "COBALT_TWITTER_GIF"
+	EnvCobaltDubLang     = "COBALT_YOUTUBE_DUB_LANG"
 	EnvYtdlpCookies      = "YTDLP_COOKIES"
+	EnvTikTokAPIURL      = "TIKTOK_API_URL"
+	EnvTikTokHD          = "TIKTOK_HD"
+	EnvTikTokMaxPosts    = "TIKTOK_MAX_POSTS"
+	EnvGalleryMaxItems   = "GALLERY_DL_MAX_ITEMS"
+	EnvPluginsDir        = "PLUGINS_DIR"
+	EnvPluginTimeout     = "PLUGIN_TIMEOUT_SECONDS"
+	EnvPluginConcurrency = "PLUGIN_CONCURRENCY"
+	EnvRecordMaxMinutes  = "RECORD_MAX_MINUTES"
+	EnvSearchBackend     = "SEARCH_BACKEND"
+	EnvSearchResults     = "SEARCH_RESULTS"
 	EnvOwnerID           = "OWNER_ID"
 	EnvEnableAdaptive    = "ENABLE_ADAPTIVE_DOWNLOAD"
 	EnvMaxFileSize       = "MAX_FILE_SIZE_MB"
@@ -53,6 +73,15 @@ const (
 
 const (
 	DefaultCobaltAPI         = ""
+	DefaultTikTokAPIURL      = "https://www.tikwm.com"
+	DefaultTikTokHD          = true
+	DefaultTikTokMaxPosts    = 30
+	DefaultGalleryMaxItems   = 100
+	DefaultPluginsDir        = "plugins"
+	DefaultPluginTimeout     = 60
+	DefaultPluginConcurrency = 4
+	DefaultRecordMaxMinutes  = 120
+	DefaultSearchResults     = 5
 	DefaultMaxFileSize       = 2000 // MB (MTProto limit ~2GB/4GB)
 	DefaultEnableAdaptive    = true
 	DefaultUpdateTimeout     = 60
@@ -74,6 +103,38 @@ const (
 	EnvMaxUploadWorkers     = "MAX_UPLOAD_WORKERS"
 )
 
+// CobaltInstance is a single Cobalt API endpoint. Requests are spread across
+// instances proportionally to Weight.
+type CobaltInstance struct {
+	URL    string
+	APIKey string
+	Bearer string
+	Weight int
+}
+
+// CobaltOptions are the defaults sent with every Cobalt request. Empty
+// strings leave the choice to the Cobalt instance.
+type CobaltOptions struct {
+	YoutubeVideoCodec string
+	AudioFormat       string
+	AudioBitrate      string
+	FilenameStyle     string
+	TiktokFullAudio   bool
+	AlwaysProxy       bool
+	DisableMetadata   bool
+	TwitterGif        bool
+	YoutubeDubLang    string
+}
+
+// CobaltOptionValues lists the accepted values of the enumerated Cobalt
+// request options, keyed by Cobalt API field name.
+var CobaltOptionValues = map[string][]string{
+	"youtubeVideoCodec": {"h264", "av1", "vp9"},
+	"audioFormat":       {"best", "mp3", "ogg", "wav", "opus"},
+	"audioBitrate":      {"320", "256", "128", "96", "64", "8"},
+	"filenameStyle":     {"classic", "pretty", "basic", "nerdy"},
+}
+
 type Config struct {
 	AppID                int
 	AppHash              string
@@ -83,7 +144,19 @@ type Config struct {
 	CookiesDir           string
 	CobaltAPI            string
 	CobaltAPIKey         string
+	CobaltInstances      []CobaltInstance
+	Cobalt               CobaltOptions
 	YtdlpCookies         string
+	TikTokAPIURL         string
+	TikTokHD             bool
+	TikTokMaxPosts       int
+	GalleryMaxItems      int
+	PluginsDir           string
+	PluginTimeout        time.Duration
+	PluginConcurrency    int
+	RecordMaxDuration    time.Duration
+	SearchBackend        string
+	SearchResults        int
 	OwnerID              int64
 	EnableAdaptive       bool
 	MaxFileSizeMB        int64
@@ -115,6 +188,16 @@ func LoadConfig() *Config {
 		CobaltAPI:            getEnvWithDefault(EnvCobaltAPI, DefaultCobaltAPI),
 		CobaltAPIKey:         os.Getenv(EnvCobaltAPIKey),
 		YtdlpCookies:         os.Getenv(EnvYtdlpCookies),
+		TikTokAPIURL:         strings.TrimRight(getEnvWithDefault(EnvTikTokAPIURL, DefaultTikTokAPIURL), "/"),
+		TikTokHD:             getBoolEnv(EnvTikTokHD, DefaultTikTokHD),
+		TikTokMaxPosts:       getIntEnv(EnvTikTokMaxPosts, DefaultTikTokMaxPosts),
+		GalleryMaxItems:      getIntEnv(EnvGalleryMaxItems, DefaultGalleryMaxItems),
+		PluginsDir:           getEnvWithDefault(EnvPluginsDir, DefaultPluginsDir),
+		PluginTimeout:        getDurationEnv(EnvPluginTimeout, DefaultPluginTimeout, time.Second),
+		PluginConcurrency:    getIntEnv(EnvPluginConcurrency, DefaultPluginConcurrency),
+		RecordMaxDuration:    getDurationEnv(EnvRecordMaxMinutes, DefaultRecordMaxMinutes, time.Minute),
+		SearchBackend:        strings.TrimRight(os.Getenv(EnvSearchBackend), "/"),
+		SearchResults:        getIntEnv(EnvSearchResults, DefaultSearchResults),
 		EnableAdaptive:       getBoolEnv(EnvEnableAdaptive, DefaultEnableAdaptive),
 		MaxConcurrentStreams: getIntEnv(EnvMaxConcurrentStreams, 0), // 0 means use adaptive/default
 		UpdateTimeout:        getIntEnv(EnvUpdateTimeout, DefaultUpdateTimeout),
@@ -138,6 +221,28 @@ func LoadConfig() *Config {
 		cfg.MaxUploadWorkers = defaultMaxUploads
 	}
 
+	// Cobalt instances, falling back to the single COBALT_API endpoint
+	cfg.CobaltInstances = parseCobaltInstances(os.Getenv(EnvCobaltInstances))
+	if len(cfg.CobaltInstances) == 0 && cfg.CobaltAPI != "" {
+		cfg.CobaltInstances = []CobaltInstance{{
+			URL:    cfg.CobaltAPI,
+			APIKey: cfg.CobaltAPIKey,
+			Weight: 1,
+		}}
+	}
+
+	cfg.Cobalt = CobaltOptions{
+		YoutubeVideoCodec: getEnumEnv(EnvCobaltVideoCodec, "youtubeVideoCodec"),
+		AudioFormat:       getEnumEnv(EnvCobaltAudioFormat, "audioFormat"),
+		AudioBitrate:      getEnumEnv(EnvCobaltAudioRate, "audioBitrate"),
+		FilenameStyle:     getEnumEnv(EnvCobaltFilename, "filenameStyle"),
+		TiktokFullAudio:   getBoolEnv(EnvCobaltTikTokAudio, false),
+		AlwaysProxy:       getBoolEnv(EnvCobaltAlwaysProxy, false),
+		DisableMetadata:   getBoolEnv(EnvCobaltNoMetadata, false),
+		TwitterGif:        getBoolEnv(EnvCobaltTwitterGif, true),
+		YoutubeDubLang:    os.Getenv(EnvCobaltDubLang),
+	}
+
 	// Owner ID
 	if ownerStr := os.Getenv(EnvOwnerID); ownerStr != "" {
 		if id, err := strconv.ParseInt(ownerStr, 10, 64); err == nil {
@@ -190,12 +295,109 @@ func GetCobaltAPIKey() string {
 	}
 	return currentConfig.CobaltAPIKey
 }
+
+func GetCobaltInstances() []CobaltInstance {
+	if currentConfig == nil {
+		return nil
+	}
+	return currentConfig.CobaltInstances
+}
+
+func GetCobaltOptions() CobaltOptions {
+	if currentConfig == nil {
+		return CobaltOptions{TwitterGif: true}
+	}
+	return currentConfig.Cobalt
+}
+
+func GetCookiesDir() string {
+	if currentConfig == nil {
+		return "cookies"
+	}
+	return currentConfig.CookiesDir
+}
+
 func GetYtdlpCookies() string {
 	if currentConfig == nil {
 		return ""
 	}
 	return currentConfig.YtdlpCookies
 }
+func GetTikTokAPIURL() string {
+	if currentConfig == nil {
+		return DefaultTikTokAPIURL
+	}
+	return currentConfig.TikTokAPIURL
+}
+
+func GetTikTokHD() bool {
+	if currentConfig == nil {
+		return DefaultTikTokHD
+	}
+	return currentConfig.TikTokHD
+}
+
+func GetTikTokMaxPosts() int {
+	if currentConfig == nil {
+		return DefaultTikTokMaxPosts
+	}
+	return currentConfig.TikTokMaxPosts
+}
+
+func GetGalleryMaxItems() int {
+	if currentConfig == nil {
+		return DefaultGalleryMaxItems
+	}
+	return currentConfig.GalleryMaxItems
+}
+
+func GetPluginsDir() string {
+	if currentConfig == nil {
+		return DefaultPluginsDir
+	}
+	return currentConfig.PluginsDir
+}
+
+func GetPluginTimeout() time.Duration {
+	if currentConfig == nil {
+		return DefaultPluginTimeout * time.Second
+	}
+	return currentConfig.PluginTimeout
+}
+
+func GetPluginConcurrency() int {
+	if currentConfig == nil || currentConfig.PluginConcurrency <= 0 {
+		return DefaultPluginConcurrency
+	}
+	return currentConfig.PluginConcurrency
+}
+
+func GetRecordMaxDuration() time.Duration {
+	if currentConfig == nil {
+		return DefaultRecordMaxMinutes * time.Minute
+	}
+	return currentConfig.RecordMaxDuration
+}
+
+// GetSearchBackend returns the Invidious instance used for /yt, or "" to
+// search with yt-dlp.
+func GetSearchBackend() string {
+	if currentConfig == nil {
+		return ""
+	}
+	return currentConfig.SearchBackend
+}
+
+func GetSearchResults() int {
+	if currentConfig == nil || currentConfig.SearchResults <= 0 {
+		return DefaultSearchResults
+	}
+	if currentConfig.SearchResults > 10 {
+		return 10
+	}
+	return currentConfig.SearchResults
+}
+
 func GetOwnerID() int64 {
 	if currentConfig == nil {
 		return 0
@@ -252,13 +454,19 @@ func ValidateConfig() error {
 		return fmt.Errorf("BOT_TOKEN is required")
 	}
 
-	if url := GetCobaltAPI(); url != "" {
-		if err := validateURL(url, "COBALT_API"); err != nil {
-			return err
+	if instances := GetCobaltInstances(); len(instances) > 0 {
+		for _, inst := range instances {
+			if err := validateURL(inst.URL, "COBALT_INSTANCES"); err != nil {
+				return err
+			}
 		}
 	} else {
 		// Warn if Cobalt is missing, but don't fail (unless required by design)
-		log.Println(" COBALT_API is not set. Cobalt provider might fail.")
+		log.Println(" COBALT_API / COBALT_INSTANCES is not set. Cobalt provider might fail.")
+	}
+
+	if err := validateURL(GetTikTokAPIURL(), EnvTikTokAPIURL); err != nil {
+		return err
 	}
 
 	log.Println(" Configuration loaded successfully:")
@@ -277,7 +485,24 @@ func PrintConfig() {
 	log.Printf("  Bot Token: %s", maskToken(cfg.BotToken))
 	log.Printf("  Cobalt API: %s", cfg.CobaltAPI)
 	log.Printf("  Cobalt API Key: %s", maskToken(cfg.CobaltAPIKey))
+	for i, inst := range cfg.CobaltInstances {
+		auth := maskToken(inst.APIKey)
+		if inst.Bearer != "" {
+			auth = "bearer " + maskToken(inst.Bearer)
+		}
+		log.Printf("  Cobalt Instance #%d: %s (weight %d, auth %s)", i+1, inst.URL, inst.Weight, auth)
+	}
+	log.Printf("  Cobalt Options: %+v", cfg.Cobalt)
 	log.Printf("  yt-dlp Cookies: %s", cfg.YtdlpCookies)
+	log.Printf("  TikTok API: %s (HD: %v, max posts: %d)", cfg.TikTokAPIURL, cfg.TikTokHD, cfg.TikTokMaxPosts)
+	log.Printf("  gallery-dl Max Items: %d", cfg.GalleryMaxItems)
+	log.Printf("  Plugins: %s (timeout %v, concurrency %d)", cfg.PluginsDir, cfg.PluginTimeout, cfg.PluginConcurrency)
+	log.Printf("  Max Recording: %v", cfg.RecordMaxDuration)
+	searchBackend := cfg.SearchBackend
+	if searchBackend == "" {
+		searchBackend = "yt-dlp"
+	}
+	log.Printf("  Search: %s (%d results)", searchBackend, cfg.SearchResults)
 	log.Printf("  Owner ID: %d", cfg.OwnerID)
 	log.Printf("  Adaptive Download: %v", cfg.EnableAdaptive)
 	log.Printf("  Max Concurrent Streams: %d", cfg.MaxConcurrentStreams)
@@ -318,6 +543,35 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnumEnv returns the value of key if it is one of the accepted values of
+// the given Cobalt option, or an empty string otherwise.
+func getEnumEnv(key, option string) string {
+	valStr := os.Getenv(key)
+	if valStr == "" {
+		return ""
+	}
+	if IsValidCobaltOption(option, valStr) {
+		return valStr
+	}
+	log.Printf("Invalid %s '%s', expected one of %v", key, valStr, CobaltOptionValues[option])
+	return ""
+}
+
+// IsValidCobaltOption reports whether value is accepted for the Cobalt
+// option. Options without a fixed value list accept anything.
+func IsValidCobaltOption(option, value string) bool {
+	allowed, ok := CobaltOptionValues[option]
+	if !ok {
+		return true
+	}
+	for _, v := range allowed {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func getDurationEnv(key string, defaultValue int, unit time.Duration) time.Duration {
 	if valStr := os.Getenv(key); valStr != "" {
 		if val, err := strconv.Atoi(valStr); err == nil && val > 0 {
@@ -339,6 +593,44 @@ func validateURL(urlStr, name string) error {
 	return nil
 }
 
+// parseCobaltInstances parses a comma separated list of Cobalt endpoints.
+// Each entry is a URL optionally followed by ";"-separated options, e.g.
+// "https://a.example;weight=3;key=xxx,https://b.example;bearer=yyy".
+func parseCobaltInstances(raw string) []CobaltInstance {
+	var instances []CobaltInstance
+	for _, entry := range strings.Split(raw, ",") {
+		fields := strings.Split(strings.TrimSpace(entry), ";")
+		if fields[0] == "" {
+			continue
+		}
+
+		inst := CobaltInstance{URL: strings.TrimSpace(fields[0]), Weight: 1}
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+			if !ok {
+				log.Printf("Invalid cobalt instance option '%s'", field)
+				continue
+			}
+			switch strings.ToLower(key) {
+			case "weight":
+				if w, err := strconv.Atoi(value); err == nil && w > 0 {
+					inst.Weight = w
+				} else {
+					log.Printf("Invalid cobalt instance weight '%s'", value)
+				}
+			case "key":
+				inst.APIKey = value
+			case "bearer":
+				inst.Bearer = value
+			default:
+				log.Printf("Unknown cobalt instance option '%s'", key)
+			}
+		}
+		instances = append(instances, inst)
+	}
+	return instances
+}
+
 func maskToken(token string) string {
 	if token == "" {
 		return "not set"
@@ -7,6 +7,7 @@ import (
 	"github.com/pavelc4/aether-tg-bot/internal/bot"
 	"github.com/pavelc4/aether-tg-bot/internal/handler"
 	"github.com/pavelc4/aether-tg-bot/internal/middleware"
+	"github.com/pavelc4/aether-tg-bot/internal/plugin"
 	"github.com/pavelc4/aether-tg-bot/internal/provider"
 	"github.com/pavelc4/aether-tg-bot/internal/streaming"
 	"github.com/pavelc4/aether-tg-bot/internal/telegram"
@@ -25,7 +26,22 @@ func New() (*App, error) {
 
 	provider.Register(provider.NewTikTok())
 	provider.Register(provider.NewYouTube())
+	provider.Register(provider.NewInstagram())
+	provider.Register(provider.NewTwitter())
+	provider.Register(provider.NewReddit())
+	provider.Register(provider.NewBluesky())
+	provider.Register(provider.NewMastodon())
+	podcastProvider := provider.NewPodcast()
+	provider.Register(podcastProvider)
+	provider.Register(provider.NewGalleryDL())
 	provider.Register(provider.NewCobalt())
+	provider.Register(provider.NewDirect())
+
+	if plugins, err := plugin.Reload(context.Background()); err != nil {
+		logger.Warn("Failed to load plugins", "error", err)
+	} else if len(plugins) > 0 {
+		logger.Info("Plugins loaded", "count", len(plugins))
+	}
 
 	maxStreams := cfg.MaxConcurrentStreams
 	if maxStreams <= 0 {
@@ -59,8 +75,13 @@ func New() (*App, error) {
 	adminHandler := handler.NewAdminHandler(client, streamMgr)
 	basicHandler := handler.NewBasicHandler(client)
 	speedtestHandler := handler.NewSpeedtestHandler(client)
+	podcastHandler := handler.NewPodcastHandler(client, dlHandler, podcastProvider)
+
+	recordHandler := handler.NewRecordHandler(client, streamMgr)
+	infoHandler := handler.NewInfoHandler(client, dlHandler)
+	searchHandler := handler.NewSearchHandler(client, dlHandler)
 
-	router := bot.NewRouter(dlHandler, adminHandler, basicHandler, speedtestHandler)
+	router := bot.NewRouter(dlHandler, adminHandler, basicHandler, speedtestHandler, podcastHandler, recordHandler, infoHandler, searchHandler)
 
 	dispatcher.OnNewMessage(func(ctx context.Context, e tg.Entities, update *tg.UpdateNewMessage) error {
 		handler := func() {
@@ -88,6 +109,32 @@ func New() (*App, error) {
 		return nil
 	})
 
+	dispatcher.OnBotCallbackQuery(func(ctx context.Context, e tg.Entities, update *tg.UpdateBotCallbackQuery) error {
+		handler := func() {
+			if err := router.OnCallbackQuery(ctx, e, update); err != nil {
+				logger.Error("OnCallbackQuery failed", "error", err)
+			}
+		}
+		go middleware.Chain(handler,
+			middleware.Recover,
+			func(next func()) func() { return middleware.Logger("OnBotCallbackQuery", next) },
+		)()
+		return nil
+	})
+
+	dispatcher.OnBotInlineQuery(func(ctx context.Context, e tg.Entities, update *tg.UpdateBotInlineQuery) error {
+		handler := func() {
+			if err := router.OnInlineQuery(ctx, e, update); err != nil {
+				logger.Error("OnInlineQuery failed", "error", err)
+			}
+		}
+		go middleware.Chain(handler,
+			middleware.Recover,
+			func(next func()) func() { return middleware.Logger("OnBotInlineQuery", next) },
+		)()
+		return nil
+	})
+
 	b := bot.New(client, router)
 
 	logger.Info("Application initialized successfully")
@@ -98,5 +145,6 @@ func New() (*App, error) {
 }
 
 func (a *App) Start(ctx context.Context) error {
+	go provider.StartHealthProbe(ctx)
 	return a.Bot.Run(ctx, a.Cfg.BotToken)
 }
@@ -15,14 +15,22 @@ type Router struct {
 	admin     *handler.AdminHandler
 	basic     *handler.BasicHandler
 	speedtest *handler.SpeedtestHandler
+	podcast   *handler.PodcastHandler
+	record    *handler.RecordHandler
+	info      *handler.InfoHandler
+	search    *handler.SearchHandler
 }
 
-func NewRouter(dl *handler.DownloadHandler, adm *handler.AdminHandler, basic *handler.BasicHandler, speed *handler.SpeedtestHandler) *Router {
+func NewRouter(dl *handler.DownloadHandler, adm *handler.AdminHandler, basic *handler.BasicHandler, speed *handler.SpeedtestHandler, podcast *handler.PodcastHandler, record *handler.RecordHandler, info *handler.InfoHandler, search *handler.SearchHandler) *Router {
 	return &Router{
 		download:  dl,
 		admin:     adm,
 		basic:     basic,
 		speedtest: speed,
+		podcast:   podcast,
+		record:    record,
+		info:      info,
+		search:    search,
 	}
 }
 
@@ -51,10 +59,52 @@ func (r *Router) OnChannelMessage(ctx context.Context, e tg.Entities, update *tg
 	return nil
 }
 
+// OnCallbackQuery handles inline keyboard button presses
+func (r *Router) OnCallbackQuery(ctx context.Context, e tg.Entities, update *tg.UpdateBotCallbackQuery) error {
+	data := string(update.Data)
+	if strings.HasPrefix(data, handler.PodcastCallbackPrefix) {
+		if err := r.podcast.HandleCallback(ctx, e, update); err != nil {
+			logger.Error("Podcast callback failed", "error", err)
+			return err
+		}
+	}
+	if strings.HasPrefix(data, handler.InfoCallbackPrefix) {
+		if err := r.info.HandleCallback(ctx, e, update); err != nil {
+			logger.Error("Info callback failed", "error", err)
+			return err
+		}
+	}
+	if strings.HasPrefix(data, handler.SearchCallbackPrefix) {
+		if err := r.search.HandleCallback(ctx, e, update); err != nil {
+			logger.Error("Search callback failed", "error", err)
+			return err
+		}
+	}
+	if strings.HasPrefix(data, handler.RecordCallbackPrefix) {
+		if err := r.record.HandleCallback(ctx, update); err != nil {
+			logger.Error("Record callback failed", "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// OnInlineQuery handles "@bot <query>" inline queries
+func (r *Router) OnInlineQuery(ctx context.Context, e tg.Entities, update *tg.UpdateBotInlineQuery) error {
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(update.Query)), handler.InlineSearchPrefix) {
+		if err := r.search.HandleInline(ctx, update); err != nil {
+			logger.Error("Inline search failed", "error", err)
+			return err
+		}
+	}
+	return nil
+}
+
 func (r *Router) HandleMessage(ctx context.Context, e tg.Entities, msg *tg.Message) error {
 	if msg.Out {
 		return nil
 	}
+	handler.RememberOwner(e)
 
 	text := msg.Message
 	isGroup := false
@@ -67,11 +117,19 @@ func (r *Router) HandleMessage(ctx context.Context, e tg.Entities, msg *tg.Messa
 		"/start":     true,
 		"/help":      true,
 		"/stats":     true,
+		"/providers": true,
+		"/plugins":   true,
+		"/cookies":   true,
 		"/speedtest": true,
 		"/speed":     true,
 		"/dl":        true,
 		"/video":     true,
 		"/mp":        true,
+		"/record":    true,
+		"/clip":      true,
+		"/info":      true,
+		"/yt":        true,
+		"/song":      true,
 	}
 
 	if strings.HasPrefix(text, "/") {
@@ -97,6 +155,30 @@ func (r *Router) HandleMessage(ctx context.Context, e tg.Entities, msg *tg.Messa
 	if strings.HasPrefix(text, "/stats") {
 		return r.admin.HandleStats(ctx, e, msg)
 	}
+	if strings.HasPrefix(text, "/providers") {
+		return r.admin.HandleProviders(ctx, e, msg)
+	}
+	if strings.HasPrefix(text, "/plugins") {
+		return r.admin.HandlePlugins(ctx, e, msg)
+	}
+	if strings.HasPrefix(text, "/cookies") {
+		return r.admin.HandleCookies(ctx, e, msg)
+	}
+	if strings.HasPrefix(text, "/clip") {
+		return r.download.HandleClip(ctx, e, msg)
+	}
+	if strings.HasPrefix(text, "/info") {
+		return r.info.Handle(ctx, e, msg)
+	}
+	if strings.HasPrefix(text, "/yt") {
+		return r.search.Handle(ctx, e, msg, false)
+	}
+	if strings.HasPrefix(text, "/song") {
+		return r.search.Handle(ctx, e, msg, true)
+	}
+	if strings.HasPrefix(text, "/record") {
+		return r.record.Handle(ctx, e, msg)
+	}
 	if strings.HasPrefix(text, "/speedtest") || strings.HasPrefix(text, "/speed") {
 		return r.speedtest.Handle(ctx, e, msg)
 	}
@@ -105,8 +187,8 @@ func (r *Router) HandleMessage(ctx context.Context, e tg.Entities, msg *tg.Messa
 		parts := strings.Fields(text)
 		if len(parts) > 1 {
 			url := parts[1]
-			if provider.ExtractURL(url) != "" && provider.IsSupported(url) {
-				return r.download.Handle(ctx, e, msg, url, false)
+			if provider.ExtractURL(url) != "" && provider.IsSupported(url, true) {
+				return r.startDownload(ctx, e, msg, url, provider.Options{Flags: parseFlags(parts[2:]), Explicit: true})
 			}
 		}
 	}
@@ -115,19 +197,19 @@ func (r *Router) HandleMessage(ctx context.Context, e tg.Entities, msg *tg.Messa
 		if len(parts) > 1 {
 			url := parts[1]
 			extracted := provider.ExtractURL(url)
-			supported := provider.IsSupported(url)
+			supported := provider.IsSupported(url, true)
 			logger.Info("Checking /mp command", "url", url, "extracted", extracted, "supported", supported)
 
 			if extracted != "" && supported {
-				return r.download.Handle(ctx, e, msg, url, true)
+				return r.startDownload(ctx, e, msg, url, provider.Options{AudioOnly: true, Flags: parseFlags(parts[2:]), Explicit: true})
 			}
 		}
 	}
 
 	url := provider.ExtractURL(text)
 	if url != "" {
-		if provider.IsSupported(url) {
-			return r.download.Handle(ctx, e, msg, url, false)
+		if provider.IsSupported(url, false) {
+			return r.startDownload(ctx, e, msg, url, provider.Options{})
 		}
 	}
 	if strings.HasPrefix(text, "/") {
@@ -139,3 +221,37 @@ func (r *Router) HandleMessage(ctx context.Context, e tg.Entities, msg *tg.Messa
 
 	return nil
 }
+
+// startDownload hands podcast feeds without a chosen episode to the episode
+// picker and everything else to the download handler.
+func (r *Router) startDownload(ctx context.Context, e tg.Entities, msg *tg.Message, url string, opts provider.Options) error {
+	if p, err := provider.GetProvider(url, opts.Explicit); err == nil {
+		if _, ok := p.(*provider.PodcastProvider); ok && opts.Flags["episode"] == "" {
+			return r.podcast.HandlePicker(ctx, e, msg, url, opts)
+		}
+	}
+	return r.download.Handle(ctx, e, msg, url, opts)
+}
+
+// parseFlags turns command arguments like "--codec av1 --always-proxy" into
+// a map. Flags without a value are set to "true".
+func parseFlags(args []string) map[string]string {
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "--") {
+			continue
+		}
+		name := strings.TrimPrefix(args[i], "--")
+		if key, value, ok := strings.Cut(name, "="); ok {
+			flags[key] = value
+			continue
+		}
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "--") {
+			flags[name] = args[i+1]
+			i++
+			continue
+		}
+		flags[name] = "true"
+	}
+	return flags
+}
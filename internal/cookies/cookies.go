@@ -0,0 +1,411 @@
+// Package cookies keeps the Netscape cookie files from CookiesDir in a pool
+// keyed by domain, rotating between accounts that cover the same site.
+package cookies
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+// Cookie is one line of a Netscape cookie file.
+type Cookie struct {
+	Domain  string
+	Path    string
+	Secure  bool
+	Expires int64 // Unix seconds, 0 for session cookies
+	Name    string
+	Value   string
+}
+
+// Jar is a single cookie file, usually one logged-in account.
+type Jar struct {
+	Path    string
+	Cookies []Cookie
+}
+
+// JarStatus describes a loaded jar for display.
+type JarStatus struct {
+	Path      string
+	Domains   []string
+	Cookies   int
+	Expires   time.Time // Earliest expiry of a persistent cookie, zero if none
+	Bad       bool
+	BadReason string
+	BadSince  time.Time
+}
+
+type badMark struct {
+	reason string
+	since  time.Time
+}
+
+type pool struct {
+	mu     sync.Mutex
+	loaded bool
+	jars   []*Jar
+	next   map[string]int
+	bad    map[string]badMark // keyed by jar path
+}
+
+var defaultPool = &pool{
+	next: make(map[string]int),
+	bad:  make(map[string]badMark),
+}
+
+// Reload rescans CookiesDir (plus the legacy YTDLP_COOKIES file) and
+// replaces the pool. Bad marks are kept for files that did not change.
+func Reload() error {
+	return defaultPool.reload()
+}
+
+// domainAliases maps short-link hosts to the domain their cookies live on.
+var domainAliases = map[string]string{
+	"youtu.be":   "youtube.com",
+	"instagr.am": "instagram.com",
+	"redd.it":    "reddit.com",
+}
+
+// Pick returns the next usable jar for domain in round-robin order, or nil
+// if no jar has cookies for it. Jars marked bad are skipped.
+func Pick(domain string) *Jar {
+	domain = normalizeDomain(domain)
+	if alias, ok := domainAliases[domain]; ok {
+		domain = alias
+	}
+	return defaultPool.pick(domain)
+}
+
+// ForURL is Pick for the host of rawURL.
+func ForURL(rawURL string) *Jar {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	return Pick(u.Hostname())
+}
+
+// MarkBad takes a jar out of rotation, e.g. after a login or age wall.
+// Reloading the pool after the file is replaced clears the mark.
+func MarkBad(j *Jar, reason string) {
+	if j == nil {
+		return
+	}
+	defaultPool.mu.Lock()
+	defer defaultPool.mu.Unlock()
+
+	if _, ok := defaultPool.bad[j.Path]; !ok {
+		logger.Warn("Cookie file marked bad", "path", j.Path, "reason", reason)
+	}
+	defaultPool.bad[j.Path] = badMark{reason: reason, since: time.Now()}
+}
+
+// Status lists the loaded jars.
+func Status() []JarStatus {
+	p := defaultPool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ensureLoaded()
+
+	out := make([]JarStatus, 0, len(p.jars))
+	for _, j := range p.jars {
+		st := JarStatus{Path: j.Path, Domains: j.Domains(), Cookies: len(j.Cookies), Expires: j.Expires()}
+		if mark, ok := p.bad[j.Path]; ok {
+			st.Bad, st.BadReason, st.BadSince = true, mark.reason, mark.since
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// Header builds a Cookie header value with the jar's unexpired cookies for
+// domain.
+func (j *Jar) Header(domain string) string {
+	if j == nil {
+		return ""
+	}
+	domain = normalizeDomain(domain)
+	now := time.Now().Unix()
+
+	seen := make(map[string]bool)
+	var pairs []string
+	for _, c := range j.Cookies {
+		if !domainMatch(domain, c.Domain) || (c.Expires != 0 && c.Expires < now) || seen[c.Name] {
+			continue
+		}
+		seen[c.Name] = true
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// Domains returns the distinct cookie domains in the jar.
+func (j *Jar) Domains() []string {
+	set := make(map[string]bool)
+	for _, c := range j.Cookies {
+		set[c.Domain] = true
+	}
+	domains := make([]string, 0, len(set))
+	for d := range set {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Expires returns the earliest expiry among persistent cookies.
+func (j *Jar) Expires() time.Time {
+	var earliest int64
+	for _, c := range j.Cookies {
+		if c.Expires > 0 && (earliest == 0 || c.Expires < earliest) {
+			earliest = c.Expires
+		}
+	}
+	if earliest == 0 {
+		return time.Time{}
+	}
+	return time.Unix(earliest, 0)
+}
+
+func (j *Jar) covers(domain string) bool {
+	for _, c := range j.Cookies {
+		if domainMatch(domain, c.Domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads a Netscape cookie file. It fails if no valid cookie line is
+// found.
+func Parse(r io.Reader) ([]Cookie, error) {
+	var cookies []Cookie
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", lineNo, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", lineNo, fields[4])
+		}
+		cookies = append(cookies, Cookie{
+			Domain:  normalizeDomain(fields[0]),
+			Path:    fields[2],
+			Secure:  strings.EqualFold(fields[3], "TRUE"),
+			Expires: expires,
+			Name:    fields[5],
+			Value:   fields[6],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cookies) == 0 {
+		return nil, fmt.Errorf("no cookies found")
+	}
+	return cookies, nil
+}
+
+func (p *pool) reload() error {
+	paths, err := filepath.Glob(filepath.Join(config.GetCookiesDir(), "*.txt"))
+	if err != nil {
+		return err
+	}
+	if legacy := config.GetYtdlpCookies(); legacy != "" {
+		abs, _ := filepath.Abs(legacy)
+		found := false
+		for _, path := range paths {
+			if a, _ := filepath.Abs(path); a == abs {
+				found = true
+				break
+			}
+		}
+		if !found {
+			paths = append(paths, legacy)
+		}
+	}
+
+	var jars []*Jar
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			logger.Warn("Failed to open cookie file", "path", path, "error", err)
+			continue
+		}
+		cookies, err := Parse(f)
+		f.Close()
+		if err != nil {
+			logger.Warn("Skipping invalid cookie file", "path", path, "error", err)
+			continue
+		}
+		jars = append(jars, &Jar{Path: path, Cookies: cookies})
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	old := make(map[string]*Jar, len(p.jars))
+	for _, j := range p.jars {
+		old[j.Path] = j
+	}
+	bad := make(map[string]badMark)
+	for _, j := range jars {
+		if mark, ok := p.bad[j.Path]; ok && old[j.Path] != nil && sameCookies(old[j.Path], j) {
+			bad[j.Path] = mark
+		}
+	}
+
+	p.jars, p.bad, p.loaded = jars, bad, true
+	logger.Info("Cookie pool loaded", "files", len(jars))
+	return nil
+}
+
+func (p *pool) ensureLoaded() {
+	if p.loaded {
+		return
+	}
+	p.mu.Unlock()
+	if err := p.reload(); err != nil {
+		logger.Warn("Failed to load cookie pool", "error", err)
+	}
+	p.mu.Lock()
+	p.loaded = true
+}
+
+func (p *pool) pick(domain string) *Jar {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ensureLoaded()
+
+	var candidates []*Jar
+	for _, j := range p.jars {
+		if _, bad := p.bad[j.Path]; !bad && j.covers(domain) {
+			candidates = append(candidates, j)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	idx := p.next[domain] % len(candidates)
+	p.next[domain] = idx + 1
+	return candidates[idx]
+}
+
+func sameCookies(a, b *Jar) bool {
+	if len(a.Cookies) != len(b.Cookies) {
+		return false
+	}
+	for i := range a.Cookies {
+		if a.Cookies[i] != b.Cookies[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func domainMatch(host, cookieDomain string) bool {
+	return host == cookieDomain || strings.HasSuffix(host, "."+cookieDomain)
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// Save validates data as a Netscape cookie file for domain and atomically
+// replaces CookiesDir/<domain>.txt with it, then reloads the pool.
+func Save(domain string, data []byte) (*Jar, error) {
+	domain = normalizeDomain(domain)
+	if !validDomain(domain) {
+		return nil, fmt.Errorf("invalid domain %q", domain)
+	}
+
+	parsed, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	jar := &Jar{Path: domainPath(domain), Cookies: parsed}
+	if !jar.covers(domain) {
+		return nil, fmt.Errorf("no cookies for %s in file", domain)
+	}
+
+	dir := config.GetCookiesDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+domain+"-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), jar.Path); err != nil {
+		return nil, err
+	}
+
+	return jar, Reload()
+}
+
+// Remove deletes CookiesDir/<domain>.txt and reloads the pool.
+func Remove(domain string) error {
+	domain = normalizeDomain(domain)
+	if !validDomain(domain) {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+	if err := os.Remove(domainPath(domain)); err != nil {
+		return err
+	}
+	return Reload()
+}
+
+func domainPath(domain string) string {
+	return filepath.Join(config.GetCookiesDir(), domain+".txt")
+}
+
+// validDomain keeps the domain safe to use as a file name.
+func validDomain(domain string) bool {
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.Contains(domain, "..") {
+		return false
+	}
+	for _, r := range domain {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
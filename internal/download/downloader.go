@@ -3,19 +3,21 @@ package download
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
 	"math/rand"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/gotd/td/tg"
-	"github.com/pavelc4/aether-tg-bot/config"
 	"github.com/pavelc4/aether-tg-bot/internal/provider"
 	"github.com/pavelc4/aether-tg-bot/internal/streaming"
 	"github.com/pavelc4/aether-tg-bot/internal/telegram"
 	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+	"github.com/pavelc4/aether-tg-bot/pkg/mediatype"
 )
 
 type Downloader struct {
@@ -43,19 +45,38 @@ func (d *Downloader) Download(ctx context.Context, infos []provider.VideoInfo, a
 			defer wg.Done()
 
 			input := streaming.StreamInput{
-				URL:      info.URL,
-				Filename: info.FileName,
-				Size:     info.FileSize,
-				Headers:  info.Headers,
-				MIME:     info.MimeType,
-				Duration: info.Duration,
-				Width:    info.Width,
-				Height:   info.Height,
+				URL:        info.URL,
+				Filename:   info.FileName,
+				Size:       info.FileSize,
+				Headers:    info.Headers,
+				MIME:       info.MimeType,
+				Duration:   info.Duration,
+				Width:      info.Width,
+				Height:     info.Height,
+				Animated:   info.Animated,
+				Title:      info.Title,
+				Performer:  info.Author,
+				AsDocument: info.AsDocument,
+				PublicOnly: info.PublicOnly,
 			}
 
 			isHLS := strings.Contains(info.URL, ".m3u8") || strings.Contains(info.URL, ".mpd") || strings.Contains(info.URL, "manifest")
 
-			if isHLS || info.UsePipe {
+			if info.Mux != nil {
+				reader, err := startMux(ctx, info)
+				if err != nil {
+					logger.Error("Failed to start mux", "file", info.FileName, "error", err)
+					return
+				}
+				input.Reader = reader
+			} else if len(info.PipeCommand) > 0 {
+				reader, err := startPipeCommand(ctx, info.PipeCommand)
+				if err != nil {
+					logger.Error("Failed to start pipe command", "file", info.FileName, "error", err)
+					return
+				}
+				input.Reader = reader
+			} else if isHLS || info.UsePipe {
 				logger.Info("Using piped download strategy", "url", info.URL, "file", info.FileName, "hls", isHLS, "pipe_flag", info.UsePipe)
 
 				args := []string{
@@ -80,7 +101,11 @@ func (d *Downloader) Download(ctx context.Context, infos []provider.VideoInfo, a
 							info.MimeType = "audio/mp4"
 						}
 					} else {
-						args = append([]string{"-f", "bestvideo[height<=1080]+bestaudio/best[height<=1080]/bestvideo+bestaudio/best", "--merge-output-format", "mkv"}, args...)
+						format := "bestvideo[height<=1080]+bestaudio/best[height<=1080]/bestvideo+bestaudio/best"
+						if info.Format != "" {
+							format = info.Format
+						}
+						args = append([]string{"-f", format, "--merge-output-format", "mkv"}, args...)
 
 						if !strings.HasSuffix(strings.ToLower(info.FileName), ".mkv") {
 							ext := filepath.Ext(info.FileName)
@@ -94,8 +119,11 @@ func (d *Downloader) Download(ctx context.Context, infos []provider.VideoInfo, a
 					}
 				}
 
-				if cookies := config.GetYtdlpCookies(); cookies != "" {
-					args = append([]string{"--cookies", cookies}, args...)
+				if info.CookieFile != "" {
+					args = append([]string{"--cookies", info.CookieFile}, args...)
+				}
+				if info.Clip != nil {
+					args = append(clipArgs(*info.Clip), args...)
 				}
 
 				cmd := exec.CommandContext(ctx, "yt-dlp", args...)
@@ -123,33 +151,19 @@ func (d *Downloader) Download(ctx context.Context, infos []provider.VideoInfo, a
 				}
 			}
 
-			isPhoto := strings.HasPrefix(input.MIME, "image/")
-			if !isPhoto {
-				name := strings.ToLower(input.Filename)
-				imgExts := []string{".jpg", ".jpeg", ".png", ".webp"}
-				for _, ext := range imgExts {
-					if strings.HasSuffix(name, ext) {
-						isPhoto = true
-						break
-					}
-				}
-			}
-
 			// Use random ID for fileID to avoid collisions
 			fileID := rand.Int63()
-			isBig := !isPhoto && input.Size > 10*1024*1024
 
-			logger.Info("Upload strategy",
-				"file", input.Filename,
-				"size", input.Size,
-				"mime", input.MIME,
-				"isPhoto", isPhoto,
-				"isBig", isBig,
-				"fileID", fileID,
-			)
+			// The pipeline classifies the stream from its first chunk, before
+			// any part is uploaded
+			media := &mediatype.Media{}
+			input.Media = media
+			input.AudioOnly = audioOnly
+			// Provider metadata often lacks size and length, or ignores rotation
+			input.Probe = &streaming.MediaProbe{}
 
 			uploadFn := func(ctx context.Context, chunk streaming.Chunk, _ int64) error {
-				return d.uploader.UploadChunk(ctx, chunk, fileID, isBig)
+				return d.uploader.UploadChunk(ctx, chunk, fileID, media.Big)
 			}
 
 			actualParts, md5sum, err := d.streamMgr.Stream(ctx, input, uploadFn, nil)
@@ -160,9 +174,27 @@ func (d *Downloader) Download(ctx context.Context, infos []provider.VideoInfo, a
 			}
 
 			if actualParts > 0 {
-				media := CreateInputMedia(input, fileID, actualParts, isBig, md5sum, audioOnly)
-				if media != nil {
-					album[i] = media
+				if probe := input.Probe; probe != nil && *probe != (streaming.MediaProbe{}) {
+					if probe.Width > 0 && probe.Height > 0 {
+						input.Width, input.Height = probe.Width, probe.Height
+						info.Width, info.Height = probe.Width, probe.Height
+					}
+					if probe.Duration > 0 {
+						input.Duration = int(probe.Duration + 0.5)
+						info.Duration = input.Duration
+					}
+					logger.Info("Probed media", "file", input.Filename, "w", probe.Width, "h", probe.Height, "dur", probe.Duration, "rotation", probe.Rotation)
+				}
+				inputMedia := CreateInputMedia(input, *media, fileID, actualParts, md5sum)
+				if doc, ok := inputMedia.(*tg.InputMediaUploadedDocument); ok && media.Kind == mediatype.Audio && info.Thumbnail != "" {
+					if thumb, err := d.uploadThumb(ctx, info.Thumbnail); err != nil {
+						logger.Warn("Failed to attach artwork", "file", info.FileName, "error", err)
+					} else {
+						doc.Thumb = thumb
+					}
+				}
+				if inputMedia != nil {
+					album[i] = inputMedia
 					uploadedInfos[i] = info
 				} else {
 					logger.Error("Failed to create input media", "file", info.FileName)
@@ -186,17 +218,49 @@ func (d *Downloader) Download(ctx context.Context, infos []provider.VideoInfo, a
 	return finalAlbum, finalInfos
 }
 
+// startPipeCommand runs argv and returns its stdout as the stream to upload.
+func startPipeCommand(ctx context.Context, argv []string) (*cmdReader, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("create stdout pipe failed: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s failed: %w", argv[0], err)
+	}
+
+	logger.Info("Started pipe command", "command", argv[0], "args", len(argv)-1)
+	return &cmdReader{
+		ReadCloser: stdout,
+		cmd:        cmd,
+		stderr:     &stderr,
+	}, nil
+}
+
 type cmdReader struct {
 	io.ReadCloser
 	cmd    *exec.Cmd
 	stderr *bytes.Buffer
+	done   chan struct{} // Closed once the process has exited, if set
+
+	interrupted atomic.Bool // Stopped on request; the non-zero exit is expected
 }
 
 func (c *cmdReader) Close() error {
 	err := c.ReadCloser.Close()
 	waitErr := c.cmd.Wait()
+	if c.done != nil {
+		close(c.done)
+	}
 
 	stderrStr := c.stderr.String()
+	if waitErr != nil && c.interrupted.Load() {
+		logger.Info("Pipe process stopped on request")
+		return err
+	}
 	if waitErr != nil {
 		stderrLen := len(stderrStr)
 		if stderrLen > 1000 {
@@ -216,3 +280,23 @@ func (c *cmdReader) Close() error {
 
 	return err
 }
+
+var (
+	ffmpegOnce      sync.Once
+	ffmpegAvailable bool
+)
+
+// clipArgs asks yt-dlp for only the clip's time range. With ffmpeg around
+// the cuts are re-encoded at exact keyframes instead of the nearest ones.
+func clipArgs(clip provider.Clip) []string {
+	ffmpegOnce.Do(func() {
+		_, err := exec.LookPath("ffmpeg")
+		ffmpegAvailable = err == nil
+	})
+
+	args := []string{"--download-sections", fmt.Sprintf("*%d-%d", int(clip.Start.Seconds()), int(clip.End.Seconds()))}
+	if ffmpegAvailable {
+		args = append(args, "--force-keyframes-at-cuts")
+	}
+	return args
+}
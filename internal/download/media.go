@@ -1,19 +1,18 @@
 package download
 
 import (
-	"strings"
-
 	"github.com/gotd/td/tg"
 	"github.com/pavelc4/aether-tg-bot/internal/streaming"
 	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+	"github.com/pavelc4/aether-tg-bot/pkg/mediatype"
 )
 
-// CreateInputMedia creates a tg.InputMediaClass based on the stream input and file details.
-func CreateInputMedia(input streaming.StreamInput, fileID int64, parts int, isBig bool, md5sum string, isAudio bool) tg.InputMediaClass {
+// CreateInputMedia creates a tg.InputMediaClass for an uploaded stream, as
+// classified by the pipeline from its first chunk.
+func CreateInputMedia(input streaming.StreamInput, media mediatype.Media, fileID int64, parts int, md5sum string) tg.InputMediaClass {
 	var inputFile tg.InputFileClass
-	var mime string
 
-	if isBig {
+	if media.Big {
 		inputFile = &tg.InputFileBig{
 			ID:    fileID,
 			Parts: parts,
@@ -28,41 +27,19 @@ func CreateInputMedia(input streaming.StreamInput, fileID int64, parts int, isBi
 		}
 	}
 
-	mime = input.MIME
-	if mime == "" {
-		mime = "video/mp4"
-	}
-
-	isMimeAudio := strings.HasPrefix(mime, "audio/")
-	isExtAudio := strings.HasSuffix(input.Filename, ".mp3") || strings.HasSuffix(input.Filename, ".m4a") || strings.HasSuffix(input.Filename, ".ogg")
-
-	if (isAudio || isExtAudio) && !isMimeAudio {
-		if strings.HasSuffix(input.Filename, ".m4a") {
-			mime = "audio/mp4"
-		} else if strings.HasSuffix(input.Filename, ".mp3") {
-			mime = "audio/mpeg"
-		} else {
-			mime = "audio/mpeg" // Fallback
-		}
-	}
+	mime := media.MIME
 
 	logger.Info("Creating media input",
 		"file", input.Filename,
 		"mime", mime,
+		"kind", media.Kind,
 		"parts", parts,
-		"isBig", isBig,
-		"isAudio", isAudio,
+		"isBig", media.Big,
 	)
 
-	isPhoto := strings.HasPrefix(mime, "image/") ||
-		strings.HasSuffix(strings.ToLower(input.Filename), ".jpg") ||
-		strings.HasSuffix(strings.ToLower(input.Filename), ".jpeg") ||
-		strings.HasSuffix(strings.ToLower(input.Filename), ".png") ||
-		strings.HasSuffix(strings.ToLower(input.Filename), ".webp") ||
-		strings.HasSuffix(strings.ToLower(input.Filename), ".gif")
-
-	if isPhoto {
-		if isBig {
+	switch media.Kind {
+	case mediatype.Photo:
+		if media.Big {
 			logger.Error("CRITICAL: Photo cannot use InputFileBig!", "file", input.Filename)
 			return nil
 		}
@@ -71,28 +48,45 @@ func CreateInputMedia(input streaming.StreamInput, fileID int64, parts int, isBi
 		return &tg.InputMediaUploadedPhoto{
 			File: inputFile, // Must be InputFile (not InputFileBig)
 		}
-	}
 
-	if strings.HasPrefix(mime, "audio/") || isAudio {
+	case mediatype.Animation:
+		logger.Info("Creating animation document", "file", input.Filename)
+		return &tg.InputMediaUploadedDocument{
+			File:     inputFile,
+			MimeType: mime,
+			Attributes: []tg.DocumentAttributeClass{
+				&tg.DocumentAttributeAnimated{},
+				&tg.DocumentAttributeFilename{
+					FileName: input.Filename,
+				},
+			},
+		}
+
+	case mediatype.Audio:
 		logger.Info("Creating audio document", "file", input.Filename)
+		title, performer := input.Title, input.Performer
+		if title == "" {
+			title = input.Filename
+		}
+		if performer == "" {
+			performer = "AetherBot"
+		}
 		return &tg.InputMediaUploadedDocument{
 			File:     inputFile,
 			MimeType: mime,
 			Attributes: []tg.DocumentAttributeClass{
 				&tg.DocumentAttributeAudio{
 					Duration:  int(input.Duration),
-					Title:     input.Filename,
-					Performer: "AetherBot",
+					Title:     title,
+					Performer: performer,
 				},
 				&tg.DocumentAttributeFilename{
 					FileName: input.Filename,
 				},
 			},
 		}
-	}
 
-	// Video files
-	if strings.HasPrefix(mime, "video/") {
+	case mediatype.Video:
 		w, h := input.Width, input.Height
 		if w == 0 || h == 0 {
 			w, h = 1280, 720
@@ -105,28 +99,35 @@ func CreateInputMedia(input streaming.StreamInput, fileID int64, parts int, isBi
 			"dur", input.Duration,
 		)
 
-		return &tg.InputMediaUploadedDocument{
-			File:     inputFile,
-			MimeType: mime,
-			Attributes: []tg.DocumentAttributeClass{
-				&tg.DocumentAttributeVideo{
-					SupportsStreaming: true,
-					Duration:          float64(input.Duration),
-					W:                 w,
-					H:                 h,
-				},
-				&tg.DocumentAttributeFilename{
-					FileName: input.Filename,
-				},
+		attributes := []tg.DocumentAttributeClass{
+			&tg.DocumentAttributeVideo{
+				SupportsStreaming: true,
+				Duration:          float64(input.Duration),
+				W:                 w,
+				H:                 h,
+			},
+			&tg.DocumentAttributeFilename{
+				FileName: input.Filename,
 			},
 		}
+		if input.Animated {
+			// Telegram autoplays and loops animated documents like GIFs
+			attributes = append(attributes, &tg.DocumentAttributeAnimated{})
+		}
+
+		return &tg.InputMediaUploadedDocument{
+			File:       inputFile,
+			MimeType:   mime,
+			Attributes: attributes,
+		}
 	}
 
-	// Default: generic document
-	logger.Info("Creating generic document", "file", input.Filename)
+	// Documents, including media sent as files on purpose
+	logger.Info("Creating file document", "file", input.Filename)
 	return &tg.InputMediaUploadedDocument{
-		File:     inputFile,
-		MimeType: mime,
+		File:      inputFile,
+		MimeType:  mime,
+		ForceFile: input.AsDocument,
 		Attributes: []tg.DocumentAttributeClass{
 			&tg.DocumentAttributeFilename{
 				FileName: input.Filename,
@@ -0,0 +1,172 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+// ffmpeg muxers that can write to a non-seekable pipe, keyed by MIME type.
+var muxFormats = map[string][]string{
+	"video/mp4":           {"-f", "mp4", "-movflags", "frag_keyframe+empty_moov+default_base_moof"},
+	"video/webm":          {"-f", "webm"},
+	"video/x-matroska":    {"-f", "matroska"},
+	"audio/mp4":           {"-f", "ipod", "-movflags", "frag_keyframe+empty_moov+default_base_moof"},
+	"audio/mpeg":          {"-f", "mp3"},
+	"audio/ogg":           {"-f", "ogg"},
+	"audio/opus":          {"-f", "ogg"},
+	"audio/wav":           {"-f", "wav"},
+	"image/gif":           {"-f", "gif"},
+	provider.SubtitleMime: {"-f", "srt"},
+}
+
+var audioCodecs = map[string]string{
+	"mp3":  "libmp3lame",
+	"opus": "libopus",
+	"ogg":  "libopus",
+	"wav":  "pcm_s16le",
+}
+
+// startMux spawns ffmpeg to merge the inputs described by info.Mux and
+// returns its stdout as the stream to upload.
+func startMux(ctx context.Context, info provider.VideoInfo) (*cmdReader, error) {
+	args, err := buildMuxArgs(info)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("create stdout pipe failed: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start ffmpeg failed: %w", err)
+	}
+
+	logger.Info("Started ffmpeg mux", "file", info.FileName, "mode", info.Mux.Mode, "inputs", len(info.Mux.Inputs))
+
+	reader := &cmdReader{
+		ReadCloser: stdout,
+		cmd:        cmd,
+		stderr:     &stderr,
+	}
+	if stop := info.Mux.Stop; stop != nil {
+		reader.done = make(chan struct{})
+		go func() {
+			select {
+			case <-stop:
+				// SIGINT makes ffmpeg flush the last fragment before exiting
+				reader.interrupted.Store(true)
+				_ = cmd.Process.Signal(os.Interrupt)
+			case <-reader.done:
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return reader, nil
+}
+
+func buildMuxArgs(info provider.VideoInfo) ([]string, error) {
+	spec := info.Mux
+	format, ok := muxFormats[info.MimeType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported mux output type: %s", info.MimeType)
+	}
+
+	args := []string{"-hide_banner", "-loglevel", "error", "-nostdin"}
+	for _, input := range spec.Inputs {
+		args = append(args, inputHeaderArgs(info.Headers)...)
+		if spec.Start > 0 {
+			args = append(args, "-ss", strconv.Itoa(spec.Start))
+		}
+		args = append(args, "-i", input)
+	}
+
+	switch spec.Mode {
+	case "merge":
+		args = append(args, "-map", "0:v:0", "-map", "1:a:0", "-c:v", "copy", "-c:a", "copy")
+	case "mute":
+		args = append(args, "-map", "0:v:0", "-an", "-c:v", "copy")
+	case "audio":
+		args = append(args, "-map", "0:a:0", "-vn")
+		if codec, ok := audioCodecs[spec.AudioFormat]; ok && !spec.AudioCopy {
+			args = append(args, "-c:a", codec)
+			if spec.AudioBitrate != "" && codec != "pcm_s16le" {
+				args = append(args, "-b:a", spec.AudioBitrate+"k")
+			}
+		} else {
+			args = append(args, "-c:a", "copy")
+		}
+	case "gif":
+		args = append(args, "-map", "0:v:0", "-an", "-loop", "0")
+	case "remux":
+		args = append(args, "-map", "0", "-c", "copy")
+	case "copy":
+		// Only the main tracks: HLS carries timed ID3 data streams that MP4 cannot hold
+		args = append(args, "-map", "0:v:0?", "-map", "0:a:0?", "-c", "copy")
+	case "srt":
+		args = append(args, "-map", "0:s:0", "-c:s", "srt")
+	default:
+		return nil, fmt.Errorf("unknown mux mode: %s", spec.Mode)
+	}
+
+	if spec.Subtitles && len(spec.Inputs) > 1 && spec.Mode != "audio" && spec.Mode != "gif" {
+		subCodec := "copy"
+		if info.MimeType == "video/mp4" {
+			subCodec = "mov_text"
+		}
+		args = append(args, "-map", fmt.Sprintf("%d:s:0?", len(spec.Inputs)-1), "-c:s", subCodec)
+	}
+
+	if spec.MaxDuration > 0 {
+		args = append(args, "-t", strconv.Itoa(spec.MaxDuration))
+	}
+
+	keys := make([]string, 0, len(spec.Metadata))
+	for k := range spec.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "-metadata", k+"="+spec.Metadata[k])
+	}
+
+	args = append(args, format...)
+	return append(args, "pipe:1"), nil
+}
+
+func inputHeaderArgs(headers map[string]string) []string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	var sb strings.Builder
+	for k, v := range headers {
+		if strings.EqualFold(k, "User-Agent") {
+			continue
+		}
+		sb.WriteString(k + ": " + v + "\r\n")
+	}
+
+	var args []string
+	if ua, ok := headers["User-Agent"]; ok {
+		args = append(args, "-user_agent", ua)
+	}
+	if sb.Len() > 0 {
+		args = append(args, "-headers", sb.String())
+	}
+	return args
+}
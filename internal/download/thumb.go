@@ -0,0 +1,63 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"os/exec"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/pavelc4/aether-tg-bot/internal/streaming"
+)
+
+const (
+	// Telegram rejects document thumbnails over 200KB or 320px
+	maxThumbSize    = 200 * 1024
+	thumbDimension  = 320
+	thumbJobTimeout = 20 * time.Second
+)
+
+// uploadThumb scales a cover image down to a Telegram document thumbnail
+// with ffmpeg and uploads it as a single-part file.
+func (d *Downloader) uploadThumb(ctx context.Context, url string) (tg.InputFileClass, error) {
+	ctx, cancel := context.WithTimeout(ctx, thumbJobTimeout)
+	defer cancel()
+
+	scale := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", thumbDimension, thumbDimension)
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-loglevel", "error", "-nostdin",
+		"-i", url,
+		"-vf", scale,
+		"-frames:v", "1",
+		"-q:v", "5",
+		"-f", "mjpeg", "pipe:1",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	data, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg thumbnail failed: %w: %s", err, stderr.String())
+	}
+	if len(data) == 0 || len(data) > maxThumbSize {
+		return nil, fmt.Errorf("thumbnail size %d out of range", len(data))
+	}
+
+	fileID := rand.Int63()
+	chunk := streaming.Chunk{PartNum: 0, TotalParts: 1, Data: data, Size: len(data)}
+	if err := d.uploader.UploadChunk(ctx, chunk, fileID, false); err != nil {
+		return nil, err
+	}
+
+	sum := md5.Sum(data)
+	return &tg.InputFile{
+		ID:          fileID,
+		Parts:       1,
+		Name:        "thumb.jpg",
+		MD5Checksum: hex.EncodeToString(sum[:]),
+	}, nil
+}
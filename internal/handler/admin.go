@@ -1,14 +1,22 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	stdhtml "html"
+	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/gotd/td/telegram/downloader"
 	"github.com/gotd/td/telegram/message"
 	"github.com/gotd/td/telegram/message/html"
 	"github.com/gotd/td/tg"
 	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/internal/cookies"
+	"github.com/pavelc4/aether-tg-bot/internal/plugin"
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
 	"github.com/pavelc4/aether-tg-bot/internal/stats"
 	"github.com/pavelc4/aether-tg-bot/internal/streaming"
 	"github.com/pavelc4/aether-tg-bot/internal/telegram"
@@ -101,7 +109,115 @@ func (h *AdminHandler) HandleStats(ctx context.Context, e tg.Entities, msg *tg.M
 	return err
 }
 
+func (h *AdminHandler) HandleProviders(ctx context.Context, e tg.Entities, msg *tg.Message) error {
+	senderID := getSenderID(msg)
+	if senderID != config.GetOwnerID() {
+		return nil // Ignore non-owner
+	}
 
+	var sb strings.Builder
+	sb.WriteString("<b>Provider Health</b>")
+
+	for _, hs := range provider.HealthSnapshot() {
+		sb.WriteString(fmt.Sprintf("\n\n<b>%s</b>\n", stdhtml.EscapeString(hs.Name)))
+		sb.WriteString(fmt.Sprintf("├ Circuit : <code>%s</code>\n", hs.State))
+		sb.WriteString(fmt.Sprintf("├ Success : <code>%.1f%% (%d/%d)</code>\n", hs.SuccessRate(), hs.Successes, hs.Successes+hs.Failures))
+		sb.WriteString(fmt.Sprintf("├ Latency : <code>p50 %s / p90 %s / p99 %s</code>\n",
+			hs.P50.Round(time.Millisecond), hs.P90.Round(time.Millisecond), hs.P99.Round(time.Millisecond)))
+
+		for _, inst := range hs.Instances {
+			status := "healthy"
+			if !inst.Healthy {
+				status = "cooling down"
+			}
+			sb.WriteString(fmt.Sprintf("├ Instance : <code>%s</code> (w%d, %s)\n", stdhtml.EscapeString(inst.URL), inst.Weight, status))
+			sb.WriteString(fmt.Sprintf("│  ├ Requests : <code>%d (%d failed)</code>\n", inst.Requests, inst.Failures))
+			if inst.RateLimit > 0 {
+				sb.WriteString(fmt.Sprintf("│  ├ Quota : <code>%d/%d left, resets %s</code>\n",
+					inst.RateRemaining, inst.RateLimit, inst.RateReset.Format("15:04:05")))
+			}
+			lastErr := "none"
+			if inst.LastError != "" {
+				lastErr = inst.LastErrorAt.Format("15:04:05") + " " + inst.LastError
+				if len(lastErr) > 120 {
+					lastErr = lastErr[:117] + "..."
+				}
+			}
+			sb.WriteString(fmt.Sprintf("│  └ Last Error : %s\n", stdhtml.EscapeString(lastErr)))
+		}
+
+		if len(hs.RecentErrors) == 0 {
+			sb.WriteString("└ Errors : <code>none</code>")
+			continue
+		}
+		sb.WriteString("└ Recent Errors")
+		for i, rec := range hs.RecentErrors {
+			prefix := "\n   ├ "
+			if i == len(hs.RecentErrors)-1 {
+				prefix = "\n   └ "
+			}
+			text := rec.Message
+			if len(text) > 120 {
+				text = text[:117] + "..."
+			}
+			sb.WriteString(fmt.Sprintf("%s<code>%s</code> %s", prefix, rec.Time.Format("15:04:05"), stdhtml.EscapeString(text)))
+		}
+	}
+
+	sender := message.NewSender(h.client.API())
+	inputPeer, err := resolvePeer(msg.PeerID, e)
+	if err != nil {
+		return err
+	}
+	_, err = sender.To(inputPeer).Reply(msg.ID).StyledText(ctx, html.String(nil, sb.String()))
+	return err
+}
+
+// HandlePlugins lists the installed plugins; "/plugins reload" rescans the
+// plugins directory.
+func (h *AdminHandler) HandlePlugins(ctx context.Context, e tg.Entities, msg *tg.Message) error {
+	senderID := getSenderID(msg)
+	if senderID != config.GetOwnerID() {
+		return nil // Ignore non-owner
+	}
+
+	inputPeer, err := resolvePeer(msg.PeerID, e)
+	if err != nil {
+		return err
+	}
+	sender := message.NewSender(h.client.API())
+
+	var sb strings.Builder
+	plugins := plugin.Installed()
+	parts := strings.Fields(msg.Message)
+	if len(parts) > 1 && parts[1] == "reload" {
+		plugins, err = plugin.Reload(ctx)
+		if err != nil {
+			_, sendErr := sender.To(inputPeer).Reply(msg.ID).Text(ctx, fmt.Sprintf("❌ Failed to reload plugins: %v", err))
+			if sendErr != nil {
+				return sendErr
+			}
+			return err
+		}
+		sb.WriteString("✅ Plugins reloaded\n\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("<b>Plugins</b> (<code>%s</code>)\n", stdhtml.EscapeString(config.GetPluginsDir())))
+	if len(plugins) == 0 {
+		sb.WriteString("└ <code>none installed</code>")
+	}
+	for i, p := range plugins {
+		prefix := "├"
+		if i == len(plugins)-1 {
+			prefix = "└"
+		}
+		sb.WriteString(fmt.Sprintf("%s <b>%s</b> : <code>%s</code> (%d patterns)\n", prefix,
+			stdhtml.EscapeString(p.Name()), stdhtml.EscapeString(filepath.Base(p.Path())), p.Patterns()))
+	}
+
+	_, err = sender.To(inputPeer).Reply(msg.ID).StyledText(ctx, html.String(nil, sb.String()))
+	return err
+}
 
 func getSenderID(msg *tg.Message) int64 {
 	if from, ok := msg.GetFromID(); ok {
@@ -114,3 +230,158 @@ func getSenderID(msg *tg.Message) int64 {
 	}
 	return 0
 }
+
+// maxCookieFileSize caps uploaded cookie files; real exports are a few KB.
+const maxCookieFileSize = 1024 * 1024
+
+// HandleCookies manages the cookie pool: "/cookies list", "/cookies set
+// <domain>" with a cookies.txt document attached or replied to, and
+// "/cookies rm <domain>". Cookie files are only taken in a private chat and
+// their message is deleted once saved.
+func (h *AdminHandler) HandleCookies(ctx context.Context, e tg.Entities, msg *tg.Message) error {
+	senderID := getSenderID(msg)
+	if senderID != config.GetOwnerID() {
+		return nil // Ignore non-owner
+	}
+
+	inputPeer, err := resolvePeer(msg.PeerID, e)
+	if err != nil {
+		return err
+	}
+	sender := message.NewSender(h.client.API())
+	reply := func(text string) error {
+		_, err := sender.To(inputPeer).Reply(msg.ID).StyledText(ctx, html.String(nil, text))
+		return err
+	}
+
+	parts := strings.Fields(msg.Message)
+	sub := "list"
+	if len(parts) > 1 {
+		sub = parts[1]
+	}
+
+	switch sub {
+	case "list":
+		return reply(cookieStatusText())
+
+	case "set":
+		if _, private := msg.PeerID.(*tg.PeerUser); !private {
+			return reply("❌ Send cookie files in a private chat with the bot")
+		}
+		if len(parts) < 3 {
+			return reply("Usage: <code>/cookies set &lt;domain&gt;</code> with a cookies.txt document attached or replied to")
+		}
+		doc, docMsgID, err := h.messageDocument(ctx, msg)
+		if err != nil {
+			return reply(fmt.Sprintf("❌ %s", stdhtml.EscapeString(err.Error())))
+		}
+		if doc.Size > maxCookieFileSize {
+			return reply("❌ File is too large for a cookie file")
+		}
+
+		var buf bytes.Buffer
+		_, err = downloader.NewDownloader().Download(h.client.API(), doc.AsInputDocumentFileLocation()).Stream(ctx, &buf)
+		if err != nil {
+			return reply(fmt.Sprintf("❌ Failed to download file: %s", stdhtml.EscapeString(err.Error())))
+		}
+
+		jar, err := cookies.Save(parts[2], buf.Bytes())
+		if err != nil {
+			return reply(fmt.Sprintf("❌ Invalid cookie file: %s", stdhtml.EscapeString(err.Error())))
+		}
+		// The session cookies should not linger in the chat history
+		deleteMessage(ctx, h.client.API(), inputPeer, docMsgID)
+
+		expires := "session only"
+		if t := jar.Expires(); !t.IsZero() {
+			expires = t.Format("2006-01-02")
+			if time.Until(t) < 0 {
+				expires += " (already expired)"
+			}
+		}
+		text := fmt.Sprintf("✅ Cookies saved\n├ <b>File</b> : <code>%s</code>\n├ <b>Cookies</b> : <code>%d</code>\n└ <b>Expires</b> : <code>%s</code>",
+			stdhtml.EscapeString(filepath.Base(jar.Path)), len(jar.Cookies), expires)
+		if docMsgID == msg.ID {
+			// The command itself carried the file and is gone now
+			_, err := sender.To(inputPeer).StyledText(ctx, html.String(nil, text))
+			return err
+		}
+		return reply(text)
+
+	case "rm":
+		if len(parts) < 3 {
+			return reply("Usage: <code>/cookies rm &lt;domain&gt;</code>")
+		}
+		if err := cookies.Remove(parts[2]); err != nil {
+			return reply(fmt.Sprintf("❌ Failed to remove cookies: %s", stdhtml.EscapeString(err.Error())))
+		}
+		return reply(fmt.Sprintf("✅ Removed cookies for <code>%s</code>", stdhtml.EscapeString(parts[2])))
+	}
+
+	return reply("Usage: <code>/cookies list|set &lt;domain&gt;|rm &lt;domain&gt;</code>")
+}
+
+func cookieStatusText() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>Cookies</b> (<code>%s</code>)\n", stdhtml.EscapeString(config.GetCookiesDir())))
+
+	jars := cookies.Status()
+	if len(jars) == 0 {
+		sb.WriteString("└ <code>none loaded</code>")
+	}
+	for i, j := range jars {
+		prefix := "├"
+		if i == len(jars)-1 {
+			prefix = "└"
+		}
+		state := "✅"
+		if j.Bad {
+			state = fmt.Sprintf("❌ %s since %s", j.BadReason, j.BadSince.Format("01-02 15:04"))
+		}
+		expires := "session"
+		if !j.Expires.IsZero() {
+			expires = j.Expires.Format("2006-01-02")
+		}
+		sb.WriteString(fmt.Sprintf("%s <b>%s</b> : %d cookies, expires %s, %d domains %s\n", prefix,
+			stdhtml.EscapeString(filepath.Base(j.Path)), j.Cookies, expires, len(j.Domains), state))
+	}
+	return sb.String()
+}
+
+// messageDocument returns the document attached to msg, or to the message
+// it replies to.
+func (h *AdminHandler) messageDocument(ctx context.Context, msg *tg.Message) (*tg.Document, int, error) {
+	if doc, ok := mediaDocument(msg.Media); ok {
+		return doc, msg.ID, nil
+	}
+
+	header, ok := msg.ReplyTo.(*tg.MessageReplyHeader)
+	if !ok || header.ReplyToMsgID == 0 {
+		return nil, 0, fmt.Errorf("attach a cookies.txt document or reply to one")
+	}
+
+	// Only called for private chats, which never need ChannelsGetMessages
+	res, err := h.client.API().MessagesGetMessages(ctx, []tg.InputMessageClass{&tg.InputMessageID{ID: header.ReplyToMsgID}})
+	if err != nil {
+		return nil, 0, fmt.Errorf("fetch replied message failed: %w", err)
+	}
+
+	if modified, ok := res.AsModified(); ok {
+		for _, m := range modified.GetMessages() {
+			if replied, ok := m.(*tg.Message); ok {
+				if doc, ok := mediaDocument(replied.Media); ok {
+					return doc, replied.ID, nil
+				}
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("replied message has no document")
+}
+
+func mediaDocument(media tg.MessageMediaClass) (*tg.Document, bool) {
+	m, ok := media.(*tg.MessageMediaDocument)
+	if !ok {
+		return nil, false
+	}
+	return m.Document.AsNotEmpty()
+}
@@ -42,11 +42,21 @@ func (h *BasicHandler) HandleHelp(ctx context.Context, e tg.Entities, msg *tg.Me
 			"├ <code>/dl [URL]</code> - Download content\n" +
 			"├ <code>/mp [URL]</code> - Download audio only\n" +
 			"├ <code>/video [URL]</code> - Download video only\n" +
+			"├ <code>/yt [query]</code> - Search YouTube\n" +
+			"├ <code>/song [query]</code> - Search YouTube Music\n" +
+			"├ <code>/info [URL]</code> - Preview formats and size before downloading\n" +
+			"├ <code>/clip [URL] [start]-[end]</code> - Download part of a video\n" +
+			"├ <code>/record [URL] [duration]</code> - Record a live stream\n" +
 			"├ <code>/speedtest</code> - Check server speed\n" +
 			"└ <code>/help</code> - Show this help message\n\n" +
 			"<b>Quick Tips</b>\n" +
 			"• Just send a URL to download video automatically\n" +
 			"• Supports <b>YouTube, TikTok, Instagram, X</b>, and more!\n" +
+			"• Send a podcast feed or episode page to pick an episode\n" +
+			"• Split a YouTube mix into tracks with <code>/mp [URL] --chapters</code>\n" +
+			"• Search inline with <code>@bot yt:[query]</code>\n" +
+			"• Get subtitles as an SRT file with <code>/dl [URL] --subs en</code>\n" +
+			"• Tune Cobalt per link, e.g. <code>/dl [URL] --codec av1 --audio-format opus</code>\n" +
 			"• Fast multithreaded downloads\n\n" +
 			"<i>Fun fact: This bot is written in Go</i> 🐹",
 	)
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
+)
+
+// defaultClipLength is used when only a start time is given via ?t=.
+const defaultClipLength = time.Minute
+
+const clipUsage = "Usage: /clip <url> <start>-<end>, e.g. /clip https://youtu.be/... 1:30-2:00\n" +
+	"A link with ?t= can give the start: /clip https://youtu.be/...?t=90 2:00"
+
+// HandleClip downloads only a time range: "/clip <url> <start>-<end>".
+func (h *DownloadHandler) HandleClip(ctx context.Context, e tg.Entities, msg *tg.Message) error {
+	parts := strings.Fields(msg.Message)
+	var url string
+	if len(parts) > 1 {
+		url = provider.ExtractURL(parts[1])
+	}
+
+	var clip *provider.Clip
+	err := fmt.Errorf("missing link")
+	if url != "" {
+		clip, err = parseClip(url, parts[2:])
+	}
+	if err != nil || !provider.IsSupported(url, true) {
+		inputPeer, perr := resolvePeer(msg.PeerID, e)
+		if perr != nil {
+			return perr
+		}
+		text := clipUsage
+		if err != nil && url != "" {
+			text = "❌ " + err.Error() + "\n\n" + clipUsage
+		}
+		_, sendErr := message.NewSender(h.client.API()).To(inputPeer).Reply(msg.ID).Text(ctx, text)
+		return sendErr
+	}
+
+	return h.Handle(ctx, e, msg, url, provider.Options{Clip: clip, Explicit: true})
+}
+
+// parseClip reads "<start>-<end>" from args. A ?t= or #t= in the link sets
+// the start, leaving args to give the end.
+func parseClip(rawURL string, args []string) (*provider.Clip, error) {
+	var start, end time.Duration
+	hasStart := false
+
+	if u, err := neturl.Parse(rawURL); err == nil {
+		t := u.Query().Get("t")
+		if t == "" {
+			if frag, err := neturl.ParseQuery(u.Fragment); err == nil {
+				t = frag.Get("t")
+			}
+		}
+		if t != "" {
+			if start, err = parseTimestamp(t); err != nil {
+				return nil, err
+			}
+			hasStart = true
+		}
+	}
+
+	var spec string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "--") {
+		spec = args[0]
+	}
+
+	var err error
+	switch {
+	case strings.Contains(spec, "-"):
+		from, to, _ := strings.Cut(spec, "-")
+		if start, err = parseTimestamp(from); err != nil {
+			return nil, err
+		}
+		if end, err = parseTimestamp(to); err != nil {
+			return nil, err
+		}
+	case spec != "" && hasStart:
+		if end, err = parseTimestamp(spec); err != nil {
+			return nil, err
+		}
+	case hasStart:
+		end = start + defaultClipLength
+	default:
+		return nil, fmt.Errorf("missing time range")
+	}
+
+	if end <= start {
+		return nil, fmt.Errorf("the end must be after the start")
+	}
+	return &provider.Clip{Start: start, End: end}, nil
+}
+
+// parseTimestamp accepts seconds ("90"), clock form ("1:30", "01:02:03")
+// and durations ("1m30s").
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.ParseFloat(s, 64); err == nil && n >= 0 {
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	if strings.Contains(s, ":") {
+		var total time.Duration
+		for _, part := range strings.Split(s, ":") {
+			n, err := strconv.Atoi(part)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid time %q", s)
+			}
+			total = total*60 + time.Duration(n)*time.Second
+		}
+		return total, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	stdhtml "html"
+
+	"github.com/gotd/td/tg"
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+// errorText renders err for the user who sent msg, in their Telegram
+// language. Error details are never shown; they go to logs and the owner.
+func errorText(e tg.Entities, msg *tg.Message, err error) string {
+	text := "❌ " + errs.KindOf(err).UserMessage(userLang(e, msg))
+	if errors.Is(err, provider.ErrLiveStream) {
+		text += "\n🔴 It's live, use /record <url> <duration> to record it."
+	}
+	return text
+}
+
+func userLang(e tg.Entities, msg *tg.Message) string {
+	if user, ok := e.Users[getSenderID(msg)]; ok {
+		return user.LangCode
+	}
+	return ""
+}
+
+// reportError logs a failed request and sends the owner the full error.
+// Content errors such as private or removed posts are only logged.
+func reportError(ctx context.Context, api *tg.Client, stage, url, providerName string, err error) {
+	kind := errs.KindOf(err)
+	logger.Error("Request failed", "stage", stage, "url", url, "provider", providerName, "kind", kind, "retryable", kind.Retryable(), "error", err)
+	if kind.Content() {
+		return
+	}
+
+	text := fmt.Sprintf("🚨 <b>Error report</b>\n├ <b>Stage</b> : <code>%s</code>\n├ <b>Provider</b> : <code>%s</code>\n├ <b>Kind</b> : <code>%s</code>\n├ <b>Link</b> : %s\n└ <b>Error</b> : <code>%s</code>",
+		stage, stdhtml.EscapeString(providerName), kind, stdhtml.EscapeString(url), stdhtml.EscapeString(err.Error()))
+	NotifyOwner(ctx, api, fmt.Sprintf("error:%s:%s:%s", stage, providerName, kind), text)
+}
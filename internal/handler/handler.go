@@ -2,7 +2,10 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	stdhtml "html"
+	"path/filepath"
 	"time"
 
 	"github.com/gotd/td/telegram/message"
@@ -34,11 +37,23 @@ func NewDownloadHandler(sm *streaming.Manager, cli *telegram.Client) *DownloadHa
 	}
 }
 
-func (h *DownloadHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Message, url string, audioOnly bool) error {
-	if !provider.IsSupported(url) {
+func (h *DownloadHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Message, url string, opts provider.Options) error {
+	return h.handle(ctx, e, msg, url, opts, nil, "")
+}
+
+// HandleResolved downloads infos that were already resolved for url, e.g.
+// by /info, skipping the cache lookup and provider resolution.
+func (h *DownloadHandler) HandleResolved(ctx context.Context, e tg.Entities, msg *tg.Message, url string, opts provider.Options, infos []provider.VideoInfo, providerName string) error {
+	return h.handle(ctx, e, msg, url, opts, infos, providerName)
+}
+
+func (h *DownloadHandler) handle(ctx context.Context, e tg.Entities, msg *tg.Message, url string, opts provider.Options, infos []provider.VideoInfo, providerName string) error {
+	if !provider.IsSupported(url, opts.Explicit) {
 		return nil
 	}
 
+	audioOnly := opts.AudioOnly
+
 	logger.Info("DownloadHandler Handle called", "url", url, "audioOnly", audioOnly)
 	api := h.client.API()
 
@@ -70,8 +85,8 @@ func (h *DownloadHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Mes
 		}
 	}
 
-	cacheKey := fmt.Sprintf("%s|%t", url, audioOnly)
-	if cached := cache.GetInstance().Get(cacheKey); cached != nil {
+	cacheKey := opts.CacheKey(url)
+	if cached := cache.GetInstance().Get(cacheKey); cached != nil && infos == nil {
 		logger.Info("Cache hit", "url", url)
 		var media tg.InputMediaClass
 		if cached.Type == cache.TypePhoto {
@@ -110,42 +125,50 @@ func (h *DownloadHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Mes
 
 	startTime := time.Now()
 
-	infos, providerName, err := provider.Resolve(ctx, url, provider.Options{AudioOnly: audioOnly})
-	if err != nil {
-		editMsg(fmt.Sprintf("❌ Failed from %s: %v", providerName, err))
-		return err
+	if infos == nil {
+		infos, providerName, err = provider.Resolve(ctx, url, opts)
+		if err != nil {
+			editMsg(errorText(e, msg, err))
+			if provider.IsAuthError(err) {
+				h.alertCookies(ctx, url, err)
+			} else {
+				reportError(ctx, api, "resolve", url, providerName, err)
+			}
+			return err
+		}
 	}
 
 	editMsg(messaging.FormatInitialProgress(infos, providerName))
 
 	uploader := telegram.NewUploader(api)
-
 	downloader := download.NewDownloader(h.streamMgr, uploader)
-	finalAlbum, finalInfos := downloader.Download(ctx, infos, audioOnly)
 
-	if len(finalAlbum) == 0 {
-		editMsg("❌ No items were successfully downloaded.")
-		return nil
-	}
-
-	logger.Info("Starting batch send", "total_items", len(finalAlbum))
+	logger.Info("Starting batch send", "total_items", len(infos))
 
 	msgSender := messaging.NewSender(api)
 	userName := messaging.GetUserName(e, msg)
 
-	for i := 0; i < len(finalAlbum); i += MaxAlbumSize {
+	// Large galleries are downloaded and sent one album at a time
+	sent := 0
+	captioned := false
+	var lastInfo provider.VideoInfo
+	for i := 0; i < len(infos); i += MaxAlbumSize {
 		end := i + MaxAlbumSize
-		if end > len(finalAlbum) {
-			end = len(finalAlbum)
+		if end > len(infos) {
+			end = len(infos)
 		}
+		isLastBatch := end == len(infos)
 
-		batch := finalAlbum[i:end]
-		batchInfos := finalInfos[i:end]
+		batch, batchInfos := downloader.Download(ctx, infos[i:end], audioOnly)
+		if len(batch) == 0 {
+			logger.Warn("No items downloaded in batch", "start", i, "end", end)
+			continue
+		}
 
 		logger.Info("Sending batch", "start", i, "end", end, "count", len(batch))
 
 		var replyTo tg.InputReplyToClass
-		if i == 0 {
+		if sent == 0 {
 			replyTo = &tg.InputReplyToMessage{ReplyToMsgID: msg.ID}
 		}
 
@@ -153,11 +176,13 @@ func (h *DownloadHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Mes
 			// Single item
 			updates, err := msgSender.SendSingle(ctx, inputPeer, replyTo, batch[0], batchInfos[0], providerName, startTime, url, userName)
 			if err != nil {
-				logger.Error("Failed to send single media", "error", err)
-				editMsg(fmt.Sprintf("❌ Upload Error (Single): %v", err))
+				editMsg(errorText(e, msg, err))
+				reportError(ctx, api, "upload", url, providerName, err)
 			} else {
 				logger.Info(" Successfully sent single media")
-				if media := getMediaFromUpdates(updates); media != nil {
+				captioned = true
+				// Cached copies are sent without the subtitle file
+				if media := getMediaFromUpdates(updates); media != nil && len(infos) == 1 && infos[0].Subtitle == nil {
 					media.Title = batchInfos[0].Title
 					media.Size = batchInfos[0].FileSize
 					media.Provider = providerName
@@ -167,42 +192,75 @@ func (h *DownloadHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Mes
 			}
 		} else {
 			// Album
-			err := msgSender.SendAlbum(ctx, inputPeer, replyTo, batch, batchInfos, providerName, startTime, url, userName, i, len(finalAlbum), i)
+			err := msgSender.SendAlbum(ctx, inputPeer, replyTo, batch, batchInfos, providerName, startTime, url, userName, sent == 0, isLastBatch)
 			if err != nil {
 				logger.Error("Failed to send album batch", "error", err)
 			}
+			captioned = captioned || isLastBatch
 		}
+		sent += len(batch)
+		lastInfo = batchInfos[len(batchInfos)-1]
 
-		if end < len(finalAlbum) {
+		if !isLastBatch {
 			time.Sleep(1 * time.Second)
 		}
 	}
 
-	if sentMsgID != 0 {
-		if channelPeer, ok := inputPeer.(*tg.InputPeerChannel); ok {
-			logger.Info("Deleting message in channel", "msg_id", sentMsgID)
-			_, err := api.ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{
-				Channel: &tg.InputChannel{
-					ChannelID:  channelPeer.ChannelID,
-					AccessHash: channelPeer.AccessHash,
-				},
-				ID: []int{sentMsgID},
-			})
-			if err != nil {
-				logger.Error("Failed to delete channel message", "error", err)
-			}
-		} else {
-			logger.Info("Deleting message in chat", "msg_id", sentMsgID)
-			_, err := api.MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{
-				ID:     []int{sentMsgID},
-				Revoke: true,
-			})
-			if err != nil {
-				logger.Error("Failed to delete message", "error", err)
-			}
+	if sent == 0 {
+		editMsg("❌ No items were successfully downloaded.")
+		return nil
+	}
+
+	// The caption rides on the last batch, which may have downloaded nothing
+	if !captioned {
+		if err := msgSender.SendCaption(ctx, inputPeer, nil, lastInfo, providerName, startTime, url, userName); err != nil {
+			logger.Error("Failed to send caption", "error", err)
 		}
 	}
 
+	h.sendSubtitles(ctx, downloader, msgSender, inputPeer, msg.ID, infos)
+
+	if sentMsgID != 0 {
+		deleteMessage(ctx, api, inputPeer, sentMsgID)
+	}
+
 	stats.TrackDownload()
 	return nil
 }
+
+// sendSubtitles sends the subtitle tracks requested with --subs as SRT
+// files after the media. A failure only loses the subtitle file.
+func (h *DownloadHandler) sendSubtitles(ctx context.Context, downloader *download.Downloader, msgSender *messaging.Sender, peer tg.InputPeerClass, replyToID int, infos []provider.VideoInfo) {
+	for _, info := range infos {
+		if info.Subtitle == nil {
+			continue
+		}
+		media, _ := downloader.Download(ctx, []provider.VideoInfo{provider.SubtitleFile(info)}, false)
+		if len(media) == 0 {
+			logger.Warn("Failed to convert subtitles", "title", info.Title, "lang", info.Subtitle.Lang)
+			continue
+		}
+
+		caption := "💬 Subtitles: " + info.Subtitle.Lang
+		if info.Subtitle.Auto {
+			caption += " (auto-generated)"
+		}
+		replyTo := &tg.InputReplyToMessage{ReplyToMsgID: replyToID}
+		if _, err := msgSender.SendFile(ctx, peer, replyTo, media[0], caption); err != nil {
+			logger.Error("Failed to send subtitles", "title", info.Title, "error", err)
+		}
+	}
+}
+
+// alertCookies tells the owner which cookie file stopped working.
+func (h *DownloadHandler) alertCookies(ctx context.Context, url string, err error) {
+	file := "none (no cookie file matches this site)"
+	var ytErr *provider.YtdlpError
+	if errors.As(err, &ytErr) && ytErr.CookieFile != "" {
+		file = filepath.Base(ytErr.CookieFile)
+	}
+
+	text := fmt.Sprintf("⚠️ <b>Cookies need attention</b>\n├ <b>Error</b> : <code>%s</code>\n├ <b>Cookie file</b> : <code>%s</code>\n└ <b>Link</b> : %s\n\nSend a fresh file with <code>/cookies set &lt;domain&gt;</code>.",
+		stdhtml.EscapeString(err.Error()), stdhtml.EscapeString(file), stdhtml.EscapeString(url))
+	NotifyOwner(ctx, h.client.API(), "cookies:"+file, text)
+}
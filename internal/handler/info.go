@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	stdhtml "html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+
+	"github.com/pavelc4/aether-tg-bot/internal/messaging"
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
+	"github.com/pavelc4/aether-tg-bot/internal/telegram"
+	"github.com/pavelc4/aether-tg-bot/internal/utils"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const (
+	InfoCallbackPrefix = "info:"
+
+	infoSessionTTL    = 10 * time.Minute
+	infoQualityLimit  = 4
+	infoSubtitleLimit = 12
+)
+
+type InfoHandler struct {
+	client   *telegram.Client
+	download *DownloadHandler
+
+	sessions *sessionStore[*infoSession]
+}
+
+// infoSession keeps a resolution made by /info so the download buttons can
+// reuse it instead of resolving the link again.
+type infoSession struct {
+	url          string
+	infos        []provider.VideoInfo
+	providerName string
+	userID       int64
+}
+
+func NewInfoHandler(cli *telegram.Client, dl *DownloadHandler) *InfoHandler {
+	return &InfoHandler{
+		client:   cli,
+		download: dl,
+		sessions: newSessionStore[*infoSession](infoSessionTTL),
+	}
+}
+
+// Handle resolves "/info <url>" without downloading and shows what a
+// download would produce, with buttons to start one.
+func (h *InfoHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Message) error {
+	api := h.client.API()
+	peer, err := resolvePeer(msg.PeerID, e)
+	if err != nil {
+		return fmt.Errorf("failed to resolve peer: %w", err)
+	}
+	sender := message.NewSender(api)
+
+	parts := strings.Fields(msg.Message)
+	if len(parts) < 2 || provider.ExtractURL(parts[1]) == "" {
+		_, err := sender.To(peer).Reply(msg.ID).Text(ctx, "Usage: /info <url>")
+		return err
+	}
+	url := provider.ExtractURL(parts[1])
+	if !provider.IsSupported(url, true) {
+		_, err := sender.To(peer).Reply(msg.ID).Text(ctx, errorText(e, msg, provider.ErrUnsupported))
+		return err
+	}
+
+	sentUpdates, err := sender.To(peer).Reply(msg.ID).Text(ctx, "🔎 Resolving...")
+	if err != nil {
+		return fmt.Errorf("send message failed: %w", err)
+	}
+	sentMsgID := getMsgID(sentUpdates)
+
+	editMsg := func(htmlText string, markup tg.ReplyMarkupClass) {
+		parsedText, entities := messaging.ParseCaptionEntities(htmlText)
+		req := &tg.MessagesEditMessageRequest{Peer: peer, ID: sentMsgID, Message: parsedText, Entities: entities}
+		if markup != nil {
+			req.SetReplyMarkup(markup)
+		}
+		if _, err := api.MessagesEditMessage(ctx, req); err != nil {
+			logger.Error("Failed to edit message", "msg_id", sentMsgID, "error", err)
+		}
+	}
+
+	infos, providerName, err := provider.Resolve(ctx, url, provider.Options{Explicit: true})
+	if err != nil {
+		editMsg(stdhtml.EscapeString(errorText(e, msg, err)), nil)
+		if provider.IsAuthError(err) {
+			h.download.alertCookies(ctx, url, err)
+		} else {
+			reportError(ctx, api, "info", url, providerName, err)
+		}
+		return err
+	}
+
+	token := h.sessions.put(&infoSession{
+		url:          url,
+		infos:        infos,
+		providerName: providerName,
+		userID:       getSenderID(msg),
+	})
+
+	editMsg(infoText(infos, providerName), infoMarkup(token, infos))
+	return nil
+}
+
+// HandleCallback starts the download picked with an /info button. Data is
+// "info:<token>:<choice>" where choice is "v", "a" or "q<height>".
+func (h *InfoHandler) HandleCallback(ctx context.Context, e tg.Entities, update *tg.UpdateBotCallbackQuery) error {
+	data := strings.TrimPrefix(string(update.Data), InfoCallbackPrefix)
+	token, choice, ok := strings.Cut(data, ":")
+	if !ok {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "Invalid selection", true)
+	}
+
+	session, ok := h.sessions.get(token)
+	if !ok {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "This preview has expired, send /info again.", true)
+	}
+	if session.userID != 0 && session.userID != update.UserID {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "Only the person who asked for the preview can start the download.", true)
+	}
+
+	if err := answerCallback(ctx, h.client.API(), update.QueryID, "⏬ Downloading...", false); err != nil {
+		logger.Warn("Failed to answer callback query", "error", err)
+	}
+
+	msg := &tg.Message{ID: update.MsgID, PeerID: update.Peer}
+	msg.SetFromID(&tg.PeerUser{UserID: update.UserID})
+
+	switch {
+	case choice == "v":
+		return h.download.HandleResolved(ctx, e, msg, session.url, provider.Options{Explicit: true}, session.infos, session.providerName)
+	case choice == "a":
+		opts := provider.Options{AudioOnly: true, Explicit: true}
+		// Piped items pick their audio format at download time; others
+		// resolve to a different URL for audio
+		if allPiped(session.infos) {
+			return h.download.HandleResolved(ctx, e, msg, session.url, opts, session.infos, session.providerName)
+		}
+		return h.download.Handle(ctx, e, msg, session.url, opts)
+	case strings.HasPrefix(choice, "q"):
+		height, err := strconv.Atoi(strings.TrimPrefix(choice, "q"))
+		if err != nil || len(session.infos) != 1 {
+			return nil
+		}
+		infos := []provider.VideoInfo{provider.WithQuality(session.infos[0], height)}
+		opts := provider.Options{Flags: map[string]string{"quality": strconv.Itoa(height)}, Explicit: true}
+		return h.download.HandleResolved(ctx, e, msg, session.url, opts, infos, session.providerName)
+	}
+	return nil
+}
+
+func infoText(infos []provider.VideoInfo, providerName string) string {
+	first := infos[0]
+	title := first.Title
+	if title == "" {
+		title = first.FileName
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("ℹ️ <b>%s</b>\n", stdhtml.EscapeString(title)))
+	if first.Author != "" {
+		sb.WriteString(fmt.Sprintf("├ Author : %s\n", stdhtml.EscapeString(first.Author)))
+	}
+	sb.WriteString(fmt.Sprintf("├ Provider : <code>%s</code>\n", stdhtml.EscapeString(providerName)))
+	if len(infos) > 1 {
+		sb.WriteString(fmt.Sprintf("├ Items : <code>%d</code>\n", len(infos)))
+	}
+	if first.Duration > 0 {
+		sb.WriteString(fmt.Sprintf("├ Duration : <code>%s</code>\n", utils.FormatDuration(time.Duration(first.Duration)*time.Second)))
+	}
+	if first.Width > 0 && first.Height > 0 {
+		sb.WriteString(fmt.Sprintf("├ Resolution : <code>%dx%d</code>\n", first.Width, first.Height))
+	}
+
+	var total int64
+	for _, info := range infos {
+		total += info.FileSize
+	}
+	size := "unknown"
+	if total > 0 {
+		size = "~" + utils.FormatBytes(uint64(total))
+	}
+	sb.WriteString(fmt.Sprintf("├ Size : <code>%s</code>\n", size))
+
+	if len(first.Formats) > 0 {
+		labels := make([]string, 0, len(first.Formats))
+		for _, f := range first.Formats {
+			label := f.Label
+			if f.Size > 0 {
+				label += " (" + utils.FormatBytes(uint64(f.Size)) + ")"
+			}
+			labels = append(labels, label)
+		}
+		sb.WriteString(fmt.Sprintf("├ Formats : %s\n", stdhtml.EscapeString(strings.Join(labels, ", "))))
+	} else if first.MimeType != "" {
+		sb.WriteString(fmt.Sprintf("├ Format : <code>%s</code>\n", stdhtml.EscapeString(first.MimeType)))
+	}
+
+	sb.WriteString(fmt.Sprintf("└ Subtitles : %s", subtitleSummary(first)))
+	return sb.String()
+}
+
+func subtitleSummary(info provider.VideoInfo) string {
+	if len(info.SubLangs) == 0 && len(info.CaptionLangs) == 0 {
+		return "none"
+	}
+
+	langs := info.SubLangs
+	more := 0
+	if len(langs) > infoSubtitleLimit {
+		more = len(langs) - infoSubtitleLimit
+		langs = langs[:infoSubtitleLimit]
+	}
+	summary := "<code>" + stdhtml.EscapeString(strings.Join(langs, ", ")) + "</code>"
+	if len(langs) == 0 {
+		summary = ""
+	}
+	if more > 0 {
+		summary += fmt.Sprintf(" and %d more", more)
+	}
+	if n := len(info.CaptionLangs); n > 0 {
+		if summary != "" {
+			summary += ", "
+		}
+		summary += fmt.Sprintf("auto-generated in %d languages", n)
+	}
+	return summary
+}
+
+func infoMarkup(token string, infos []provider.VideoInfo) *tg.ReplyInlineMarkup {
+	button := func(text, choice string) tg.KeyboardButtonClass {
+		return &tg.KeyboardButtonCallback{Text: text, Data: []byte(InfoCallbackPrefix + token + ":" + choice)}
+	}
+
+	rows := []tg.KeyboardButtonRow{{
+		Buttons: []tg.KeyboardButtonClass{button("🎬 Video", "v"), button("🎵 Audio", "a")},
+	}}
+
+	// Quality only applies to items that yt-dlp downloads itself
+	if len(infos) == 1 && infos[0].UsePipe {
+		var qualities []tg.KeyboardButtonClass
+		for _, f := range infos[0].Formats {
+			if f.Height == 0 || f.Height > 2160 {
+				continue
+			}
+			qualities = append(qualities, button(f.Label, "q"+strconv.Itoa(f.Height)))
+			if len(qualities) == infoQualityLimit {
+				break
+			}
+		}
+		if len(qualities) > 0 {
+			rows = append(rows, tg.KeyboardButtonRow{Buttons: qualities})
+		}
+	}
+	return &tg.ReplyInlineMarkup{Rows: rows}
+}
+
+func allPiped(infos []provider.VideoInfo) bool {
+	for _, info := range infos {
+		if !info.UsePipe {
+			return false
+		}
+	}
+	return len(infos) > 0
+}
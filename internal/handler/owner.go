@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/tg"
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+// ownerAlertInterval limits how often the same alert is sent to the owner.
+const ownerAlertInterval = time.Hour
+
+var owner = struct {
+	mu         sync.Mutex
+	accessHash int64
+	known      bool
+	lastAlert  map[string]time.Time
+}{lastAlert: make(map[string]time.Time)}
+
+// RememberOwner stores the owner's access hash when it appears in an
+// update, so alerts can be sent to the owner outside of a reply.
+func RememberOwner(e tg.Entities) {
+	user, ok := e.Users[config.GetOwnerID()]
+	if !ok {
+		return
+	}
+	owner.mu.Lock()
+	owner.accessHash, owner.known = user.AccessHash, true
+	owner.mu.Unlock()
+}
+
+// NotifyOwner sends an HTML message to the owner, at most once per
+// ownerAlertInterval for the same key.
+func NotifyOwner(ctx context.Context, api *tg.Client, key, text string) {
+	ownerID := config.GetOwnerID()
+	if ownerID == 0 {
+		return
+	}
+
+	owner.mu.Lock()
+	if last, ok := owner.lastAlert[key]; ok && time.Since(last) < ownerAlertInterval {
+		owner.mu.Unlock()
+		return
+	}
+	owner.lastAlert[key] = time.Now()
+	accessHash, known := owner.accessHash, owner.known
+	owner.mu.Unlock()
+
+	if !known {
+		logger.Warn("Owner not seen yet, alert may not be delivered", "key", key)
+	}
+
+	peer := &tg.InputPeerUser{UserID: ownerID, AccessHash: accessHash}
+	if _, err := message.NewSender(api).To(peer).StyledText(ctx, html.String(nil, text)); err != nil {
+		logger.Error("Failed to notify owner", "key", key, "error", err)
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	stdhtml "html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/tg"
+
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
+	"github.com/pavelc4/aether-tg-bot/internal/telegram"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const (
+	PodcastCallbackPrefix = "pod:"
+
+	podcastPickerSize = 10
+	podcastSessionTTL = 30 * time.Minute
+)
+
+type PodcastHandler struct {
+	client   *telegram.Client
+	download *DownloadHandler
+	podcast  *provider.PodcastProvider
+
+	sessions *sessionStore[*podcastSession]
+}
+
+// podcastSession remembers the episodes offered by one picker message so a
+// button press can be mapped back to an episode GUID.
+type podcastSession struct {
+	url    string
+	opts   provider.Options
+	guids  []string
+	userID int64
+}
+
+func NewPodcastHandler(cli *telegram.Client, dl *DownloadHandler, podcast *provider.PodcastProvider) *PodcastHandler {
+	return &PodcastHandler{
+		client:   cli,
+		download: dl,
+		podcast:  podcast,
+		sessions: newSessionStore[*podcastSession](podcastSessionTTL),
+	}
+}
+
+// HandlePicker lists the latest episodes of a feed as inline buttons. Links
+// to a single episode skip the picker and download it straight away.
+func (h *PodcastHandler) HandlePicker(ctx context.Context, e tg.Entities, msg *tg.Message, url string, opts provider.Options) error {
+	peer, err := resolvePeer(msg.PeerID, e)
+	if err != nil {
+		return err
+	}
+	sender := message.NewSender(h.client.API())
+
+	feed, match, err := h.podcast.Feed(ctx, url)
+	if err != nil {
+		_, sendErr := sender.To(peer).Reply(msg.ID).Text(ctx, errorText(e, msg, err))
+		if sendErr != nil {
+			return sendErr
+		}
+		reportError(ctx, h.client.API(), "podcast", url, "Podcast", err)
+		return err
+	}
+
+	if match >= 0 {
+		return h.download.Handle(ctx, e, msg, url, withEpisode(opts, feed.Episodes[match].GUID))
+	}
+
+	episodes := feed.Episodes
+	if len(episodes) > podcastPickerSize {
+		episodes = episodes[:podcastPickerSize]
+	}
+
+	session := &podcastSession{
+		url:    url,
+		opts:   opts,
+		userID: getSenderID(msg),
+	}
+	for _, ep := range episodes {
+		session.guids = append(session.guids, ep.GUID)
+	}
+	token := h.sessions.put(session)
+
+	rows := make([]tg.KeyboardButtonRow, 0, len(episodes))
+	for i, ep := range episodes {
+		rows = append(rows, tg.KeyboardButtonRow{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{
+					Text: episodeLabel(i, ep),
+					Data: []byte(fmt.Sprintf("%s%s:%d", PodcastCallbackPrefix, token, i)),
+				},
+			},
+		})
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🎙 <b>%s</b>\n", stdhtml.EscapeString(feed.Title)))
+	if feed.Author != "" {
+		sb.WriteString(fmt.Sprintf("├ Host : %s\n", stdhtml.EscapeString(feed.Author)))
+	}
+	sb.WriteString(fmt.Sprintf("├ Episodes : <code>%d</code>\n", len(feed.Episodes)))
+	sb.WriteString(fmt.Sprintf("└ Pick one of the latest %d below", len(episodes)))
+
+	_, err = sender.To(peer).Reply(msg.ID).Markup(&tg.ReplyInlineMarkup{Rows: rows}).StyledText(ctx, html.String(nil, sb.String()))
+	return err
+}
+
+// HandleCallback downloads the episode behind a picker button.
+func (h *PodcastHandler) HandleCallback(ctx context.Context, e tg.Entities, update *tg.UpdateBotCallbackQuery) error {
+	data := strings.TrimPrefix(string(update.Data), PodcastCallbackPrefix)
+	token, idxStr, ok := strings.Cut(data, ":")
+	idx, err := strconv.Atoi(idxStr)
+	if !ok || err != nil {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "Invalid selection", true)
+	}
+
+	session, found := h.sessions.get(token)
+	if !found || idx < 0 || idx >= len(session.guids) {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "This picker has expired, send the link again.", true)
+	}
+	if session.userID != 0 && session.userID != update.UserID {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "Only the person who sent the link can pick an episode.", true)
+	}
+
+	if err := answerCallback(ctx, h.client.API(), update.QueryID, "⏬ Downloading episode...", false); err != nil {
+		logger.Warn("Failed to answer callback query", "error", err)
+	}
+
+	msg := &tg.Message{ID: update.MsgID, PeerID: update.Peer}
+	msg.SetFromID(&tg.PeerUser{UserID: update.UserID})
+
+	return h.download.Handle(ctx, e, msg, session.url, withEpisode(session.opts, session.guids[idx]))
+}
+
+func withEpisode(opts provider.Options, guid string) provider.Options {
+	flags := make(map[string]string, len(opts.Flags)+1)
+	for k, v := range opts.Flags {
+		flags[k] = v
+	}
+	flags["episode"] = guid
+	opts.Flags = flags
+	return opts
+}
+
+func episodeLabel(i int, ep provider.PodcastEpisode) string {
+	title := ep.Title
+	if runes := []rune(title); len(runes) > 48 {
+		title = string(runes[:45]) + "..."
+	}
+	label := fmt.Sprintf("%d. %s", i+1, title)
+	if ep.Duration > 0 {
+		label += fmt.Sprintf(" (%d:%02d)", ep.Duration/60, ep.Duration%60)
+	}
+	return label
+}
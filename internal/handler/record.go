@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/tg"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/internal/download"
+	"github.com/pavelc4/aether-tg-bot/internal/messaging"
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
+	"github.com/pavelc4/aether-tg-bot/internal/stats"
+	"github.com/pavelc4/aether-tg-bot/internal/streaming"
+	"github.com/pavelc4/aether-tg-bot/internal/telegram"
+	"github.com/pavelc4/aether-tg-bot/internal/utils"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const (
+	RecordCallbackPrefix = "rec:"
+
+	recordProgressInterval = 15 * time.Second
+)
+
+type RecordHandler struct {
+	client    *telegram.Client
+	streamMgr *streaming.Manager
+
+	mu   sync.Mutex
+	jobs map[string]*recordJob
+}
+
+// recordJob is a running recording that its Stop button can end early.
+type recordJob struct {
+	userID   int64
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (j *recordJob) end() {
+	j.stopOnce.Do(func() { close(j.stop) })
+}
+
+func NewRecordHandler(cli *telegram.Client, sm *streaming.Manager) *RecordHandler {
+	return &RecordHandler{
+		client:    cli,
+		streamMgr: sm,
+		jobs:      make(map[string]*recordJob),
+	}
+}
+
+// Handle records "/record <url> <duration>" from a live stream and uploads
+// it while it is being captured.
+func (h *RecordHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Message) error {
+	api := h.client.API()
+	inputPeer, err := resolvePeer(msg.PeerID, e)
+	if err != nil {
+		return fmt.Errorf("failed to resolve peer: %w", err)
+	}
+	sender := message.NewSender(api)
+
+	parts := strings.Fields(msg.Message)
+	if len(parts) < 3 || provider.ExtractURL(parts[1]) == "" {
+		_, err := sender.To(inputPeer).Reply(msg.ID).Text(ctx, "Usage: /record <url> <duration>, e.g. /record https://youtu.be/... 30m")
+		return err
+	}
+	url := provider.ExtractURL(parts[1])
+
+	maxDuration := config.GetRecordMaxDuration()
+	duration, err := parseRecordDuration(parts[2])
+	if err != nil || duration <= 0 {
+		_, err := sender.To(inputPeer).Reply(msg.ID).Text(ctx, "❌ Invalid duration, use e.g. 90s, 30m, 1h30m or 01:30:00")
+		return err
+	}
+	if duration > maxDuration {
+		_, err := sender.To(inputPeer).Reply(msg.ID).Text(ctx, fmt.Sprintf("❌ Recordings are limited to %s", utils.FormatDuration(maxDuration)))
+		return err
+	}
+
+	sentUpdates, err := sender.To(inputPeer).Reply(msg.ID).Text(ctx, "🔎 Checking live stream...")
+	if err != nil {
+		return fmt.Errorf("send message failed: %w", err)
+	}
+	sentMsgID := getMsgID(sentUpdates)
+
+	editMsg := func(text string, markup tg.ReplyMarkupClass) {
+		req := &tg.MessagesEditMessageRequest{Peer: inputPeer, ID: sentMsgID, Message: text}
+		if markup != nil {
+			req.SetReplyMarkup(markup)
+		}
+		if _, err := api.MessagesEditMessage(ctx, req); err != nil {
+			logger.Warn("Failed to edit record status", "msg_id", sentMsgID, "error", err)
+		}
+	}
+
+	token := strconv.FormatInt(rand.Int63(), 36)
+	job := &recordJob{userID: getSenderID(msg), stop: make(chan struct{})}
+	h.mu.Lock()
+	h.jobs[token] = job
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		delete(h.jobs, token)
+		h.mu.Unlock()
+	}()
+
+	info, err := provider.ResolveLive(ctx, url, duration, job.stop)
+	if err != nil {
+		editMsg(errorText(e, msg, err), nil)
+		reportError(ctx, api, "record", url, "Live", err)
+		return err
+	}
+
+	stopMarkup := &tg.ReplyInlineMarkup{
+		Rows: []tg.KeyboardButtonRow{{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{Text: "⏹ Stop and send", Data: []byte(RecordCallbackPrefix + token)},
+			},
+		}},
+	}
+
+	startTime := time.Now()
+	progressDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(recordProgressInterval)
+		defer ticker.Stop()
+		for {
+			editMsg(recordStatus(info.Title, time.Since(startTime), duration), stopMarkup)
+			select {
+			case <-ticker.C:
+			case <-job.stop:
+				editMsg(fmt.Sprintf("⏹ Stopping, finishing upload... (%s)", utils.FormatDuration(time.Since(startTime))), nil)
+				return
+			case <-progressDone:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	downloader := download.NewDownloader(h.streamMgr, telegram.NewUploader(api))
+	media, infos := downloader.Download(ctx, []provider.VideoInfo{info}, false)
+	close(progressDone)
+
+	elapsed := time.Since(startTime)
+	if elapsed > duration {
+		elapsed = duration
+	}
+
+	if len(media) == 0 {
+		err := errs.Errorf(errs.UpstreamDown, "recording produced no data after %s", utils.FormatDuration(elapsed))
+		editMsg(errorText(e, msg, err), nil)
+		reportError(ctx, api, "record", url, "Live", err)
+		return err
+	}
+
+	// The length is only known once ffmpeg has stopped
+	infos[0].Duration = int(elapsed.Seconds())
+	if doc, ok := media[0].(*tg.InputMediaUploadedDocument); ok {
+		for _, attr := range doc.Attributes {
+			if video, ok := attr.(*tg.DocumentAttributeVideo); ok {
+				video.Duration = elapsed.Seconds()
+			}
+		}
+	}
+
+	userName := messaging.GetUserName(e, msg)
+	replyTo := &tg.InputReplyToMessage{ReplyToMsgID: msg.ID}
+	if _, err := messaging.NewSender(api).SendSingle(ctx, inputPeer, replyTo, media[0], infos[0], "Live", startTime, url, userName); err != nil {
+		editMsg(errorText(e, msg, err), nil)
+		reportError(ctx, api, "upload", url, "Live", err)
+		return err
+	}
+
+	deleteMessage(ctx, api, inputPeer, sentMsgID)
+	stats.TrackDownload()
+	return nil
+}
+
+// HandleCallback ends a recording early; what was captured is still sent.
+func (h *RecordHandler) HandleCallback(ctx context.Context, update *tg.UpdateBotCallbackQuery) error {
+	token := strings.TrimPrefix(string(update.Data), RecordCallbackPrefix)
+
+	h.mu.Lock()
+	job, ok := h.jobs[token]
+	h.mu.Unlock()
+
+	text := "⏹ Stopping recording..."
+	switch {
+	case !ok:
+		text = "This recording has already finished."
+	case job.userID != update.UserID && update.UserID != config.GetOwnerID():
+		text = "Only the person who started the recording can stop it."
+	default:
+		job.end()
+	}
+
+	return answerCallback(ctx, h.client.API(), update.QueryID, text, false)
+}
+
+func recordStatus(title string, elapsed, total time.Duration) string {
+	if len([]rune(title)) > 40 {
+		title = string([]rune(title)[:37]) + "..."
+	}
+	return fmt.Sprintf("🔴 Recording %s\n%s / %s", title, utils.FormatDuration(elapsed), utils.FormatDuration(total))
+}
+
+// parseRecordDuration is parseTimestamp, except that plain numbers are
+// minutes rather than seconds.
+func parseRecordDuration(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Minute, nil
+	}
+	return parseTimestamp(s)
+}
@@ -0,0 +1,281 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	stdhtml "html"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram/message"
+	"github.com/gotd/td/telegram/message/html"
+	"github.com/gotd/td/tg"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
+	"github.com/pavelc4/aether-tg-bot/internal/telegram"
+	"github.com/pavelc4/aether-tg-bot/internal/utils"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const (
+	SearchCallbackPrefix = "yt:"
+
+	// InlineSearchPrefix starts inline queries that search YouTube, as in
+	// "@bot yt:<query>".
+	InlineSearchPrefix = "yt:"
+
+	searchSessionTTL     = 30 * time.Minute
+	inlineSearchCacheTTL = 300 // seconds
+
+	// Telegram sends an inline query per keystroke, so searches are capped,
+	// cached and limited to one per user at a time
+	inlineSearchConcurrency = 4
+	inlineResultsTTL        = 5 * time.Minute
+)
+
+type SearchHandler struct {
+	client   *telegram.Client
+	download *DownloadHandler
+
+	sessions *sessionStore[*searchSession]
+
+	inlineSlots  chan struct{}
+	inlineMu     sync.Mutex
+	inlineBusy   map[int64]bool
+	inlineCached map[string]inlineResults
+}
+
+// inlineResults are the results of one inline query, kept so the same
+// query from anyone is answered without searching again.
+type inlineResults struct {
+	results []provider.SearchResult
+	expires time.Time
+}
+
+// searchSession remembers the results listed by one /yt or /song reply so
+// a button press can be mapped back to a video.
+type searchSession struct {
+	results []provider.SearchResult
+	userID  int64
+}
+
+func NewSearchHandler(cli *telegram.Client, dl *DownloadHandler) *SearchHandler {
+	return &SearchHandler{
+		client:   cli,
+		download: dl,
+		sessions: newSessionStore[*searchSession](searchSessionTTL),
+
+		inlineSlots:  make(chan struct{}, inlineSearchConcurrency),
+		inlineBusy:   make(map[int64]bool),
+		inlineCached: make(map[string]inlineResults),
+	}
+}
+
+// Handle answers "/yt <query>" and, with music set, "/song <query>" with a
+// numbered list of results and download buttons.
+func (h *SearchHandler) Handle(ctx context.Context, e tg.Entities, msg *tg.Message, music bool) error {
+	api := h.client.API()
+	peer, err := resolvePeer(msg.PeerID, e)
+	if err != nil {
+		return fmt.Errorf("failed to resolve peer: %w", err)
+	}
+	sender := message.NewSender(api)
+
+	cmd, query, _ := strings.Cut(msg.Message, " ")
+	query = strings.TrimSpace(query)
+	if query == "" {
+		_, err := sender.To(peer).Reply(msg.ID).Text(ctx, fmt.Sprintf("Usage: %s <query>", strings.SplitN(cmd, "@", 2)[0]))
+		return err
+	}
+
+	results, err := provider.Search(ctx, query, music, config.GetSearchResults())
+	if err != nil {
+		_, sendErr := sender.To(peer).Reply(msg.ID).Text(ctx, errorText(e, msg, err))
+		reportError(ctx, api, "search", query, "Search", err)
+		if sendErr != nil {
+			return sendErr
+		}
+		return err
+	}
+	if len(results) == 0 {
+		_, err := sender.To(peer).Reply(msg.ID).Text(ctx, "🔎 No results found.")
+		return err
+	}
+
+	token := h.sessions.put(&searchSession{
+		results: results,
+		userID:  getSenderID(msg),
+	})
+
+	var sb strings.Builder
+	icon := "🔎"
+	if music {
+		icon = "🎵"
+	}
+	sb.WriteString(fmt.Sprintf("%s <b>%s</b>\n", icon, stdhtml.EscapeString(query)))
+
+	rows := make([]tg.KeyboardButtonRow, 0, len(results))
+	for i, r := range results {
+		sb.WriteString(fmt.Sprintf("\n<b>%d.</b> %s\n", i+1, stdhtml.EscapeString(r.Title)))
+		sb.WriteString(fmt.Sprintf("└ %s\n", stdhtml.EscapeString(searchResultLine(r))))
+
+		data := fmt.Sprintf("%s%s:%d:", SearchCallbackPrefix, token, i)
+		rows = append(rows, tg.KeyboardButtonRow{
+			Buttons: []tg.KeyboardButtonClass{
+				&tg.KeyboardButtonCallback{Text: fmt.Sprintf("%d. ⏬ Video", i+1), Data: []byte(data + "v")},
+				&tg.KeyboardButtonCallback{Text: fmt.Sprintf("%d. 🎵 Audio", i+1), Data: []byte(data + "a")},
+			},
+		})
+	}
+
+	_, err = sender.To(peer).Reply(msg.ID).Markup(&tg.ReplyInlineMarkup{Rows: rows}).StyledText(ctx, html.String(nil, sb.String()))
+	return err
+}
+
+// HandleCallback downloads the result behind a button. Data is
+// "yt:<token>:<index>:<v|a>".
+func (h *SearchHandler) HandleCallback(ctx context.Context, e tg.Entities, update *tg.UpdateBotCallbackQuery) error {
+	parts := strings.Split(strings.TrimPrefix(string(update.Data), SearchCallbackPrefix), ":")
+	if len(parts) != 3 {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "Invalid selection", true)
+	}
+	idx, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "Invalid selection", true)
+	}
+
+	session, ok := h.sessions.get(parts[0])
+	if !ok || idx < 0 || idx >= len(session.results) {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "These results have expired, search again.", true)
+	}
+	if session.userID != 0 && session.userID != update.UserID {
+		return answerCallback(ctx, h.client.API(), update.QueryID, "Only the person who searched can pick a result.", true)
+	}
+
+	if err := answerCallback(ctx, h.client.API(), update.QueryID, "⏬ Downloading...", false); err != nil {
+		logger.Warn("Failed to answer callback query", "error", err)
+	}
+
+	msg := &tg.Message{ID: update.MsgID, PeerID: update.Peer}
+	msg.SetFromID(&tg.PeerUser{UserID: update.UserID})
+
+	opts := provider.Options{AudioOnly: parts[2] == "a"}
+	return h.download.Handle(ctx, e, msg, session.results[idx].URL(), opts)
+}
+
+// HandleInline answers "@bot yt:<query>" with the results as articles.
+// Picking one sends its link, which the bot then downloads.
+func (h *SearchHandler) HandleInline(ctx context.Context, update *tg.UpdateBotInlineQuery) error {
+	query := strings.TrimSpace(update.Query)
+	if len(query) < len(InlineSearchPrefix) || !strings.EqualFold(query[:len(InlineSearchPrefix)], InlineSearchPrefix) {
+		return nil
+	}
+	query = strings.TrimSpace(query[len(InlineSearchPrefix):])
+	if query == "" {
+		return nil
+	}
+
+	results, ok, err := h.inlineSearch(ctx, update.UserID, query)
+	if err != nil {
+		logger.Warn("Inline search failed", "query", query, "error", err)
+		return err
+	}
+	if !ok {
+		// Superseded by the user's next keystroke or over capacity
+		return nil
+	}
+
+	articles := make([]tg.InputBotInlineResultClass, 0, len(results))
+	for _, r := range results {
+		article := &tg.InputBotInlineResult{
+			ID:          r.ID,
+			Type:        "article",
+			Title:       r.Title,
+			Description: searchResultLine(r),
+			URL:         r.URL(),
+			SendMessage: &tg.InputBotInlineMessageText{
+				Message: r.URL(),
+			},
+		}
+		article.SetThumb(tg.InputWebDocument{
+			URL:      r.Thumbnail(),
+			MimeType: "image/jpeg",
+		})
+		articles = append(articles, article)
+	}
+
+	_, err = h.client.API().MessagesSetInlineBotResults(ctx, &tg.MessagesSetInlineBotResultsRequest{
+		QueryID:   update.QueryID,
+		Results:   articles,
+		CacheTime: inlineSearchCacheTTL,
+	})
+	return err
+}
+
+// inlineSearch returns cached results for query or searches for them. It
+// reports false without searching while userID already has a search
+// running or all slots are taken.
+func (h *SearchHandler) inlineSearch(ctx context.Context, userID int64, query string) ([]provider.SearchResult, bool, error) {
+	key := strings.ToLower(query)
+
+	h.inlineMu.Lock()
+	if cached, ok := h.inlineCached[key]; ok && time.Now().Before(cached.expires) {
+		h.inlineMu.Unlock()
+		return cached.results, true, nil
+	}
+	if h.inlineBusy[userID] {
+		h.inlineMu.Unlock()
+		return nil, false, nil
+	}
+	h.inlineBusy[userID] = true
+	h.inlineMu.Unlock()
+
+	defer func() {
+		h.inlineMu.Lock()
+		delete(h.inlineBusy, userID)
+		h.inlineMu.Unlock()
+	}()
+
+	// A query that waited for a slot would be stale by the time it ran
+	select {
+	case h.inlineSlots <- struct{}{}:
+		defer func() { <-h.inlineSlots }()
+	default:
+		return nil, false, nil
+	}
+
+	results, err := provider.Search(ctx, query, false, config.GetSearchResults())
+	if err != nil {
+		return nil, false, err
+	}
+
+	h.inlineMu.Lock()
+	now := time.Now()
+	for k, cached := range h.inlineCached {
+		if now.After(cached.expires) {
+			delete(h.inlineCached, k)
+		}
+	}
+	h.inlineCached[key] = inlineResults{results: results, expires: now.Add(inlineResultsTTL)}
+	h.inlineMu.Unlock()
+
+	return results, true, nil
+}
+
+// searchResultLine is "Channel · 03:45" with whatever of the two is known.
+func searchResultLine(r provider.SearchResult) string {
+	var parts []string
+	if r.Channel != "" {
+		parts = append(parts, r.Channel)
+	}
+	if r.Duration > 0 {
+		parts = append(parts, utils.FormatDuration(time.Duration(r.Duration)*time.Second))
+	}
+	if len(parts) == 0 {
+		return "YouTube"
+	}
+	return strings.Join(parts, " · ")
+}
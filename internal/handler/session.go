@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+)
+
+// sessionStore keeps the state behind a message's inline buttons, keyed by
+// a random token that is embedded in the callback data.
+type sessionStore[T any] struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	sessions map[string]sessionEntry[T]
+}
+
+type sessionEntry[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func newSessionStore[T any](ttl time.Duration) *sessionStore[T] {
+	return &sessionStore[T]{
+		ttl:      ttl,
+		sessions: make(map[string]sessionEntry[T]),
+	}
+}
+
+// put stores value under a new token and returns the token. Expired
+// sessions are dropped on the way.
+func (s *sessionStore[T]) put(value T) string {
+	token := strconv.FormatInt(rand.Int63(), 36)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for t, entry := range s.sessions {
+		if now.After(entry.expires) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = sessionEntry[T]{value: value, expires: now.Add(s.ttl)}
+	return token
+}
+
+// get returns the session stored under token, if it has not expired.
+func (s *sessionStore[T]) get(token string) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.sessions[token]
+	if !ok || time.Now().After(entry.expires) {
+		var zero T
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// answerCallback answers a button press, as a popup alert if alert is set.
+func answerCallback(ctx context.Context, api *tg.Client, queryID int64, text string, alert bool) error {
+	_, err := api.MessagesSetBotCallbackAnswer(ctx, &tg.MessagesSetBotCallbackAnswerRequest{
+		QueryID: queryID,
+		Message: text,
+		Alert:   alert,
+	})
+	return err
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/gotd/td/tg"
 	"github.com/pavelc4/aether-tg-bot/internal/cache"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
 )
 
 // resolvePeer converts a PeerClass to InputPeerClass using the provided entities.
@@ -112,3 +114,30 @@ func getMediaFromUpdates(updates tg.UpdatesClass) *cache.CachedMedia {
 	
 	return nil
 }
+
+// deleteMessage removes one of the bot's messages, e.g. a progress status.
+func deleteMessage(ctx context.Context, api *tg.Client, peer tg.InputPeerClass, msgID int) {
+	if channelPeer, ok := peer.(*tg.InputPeerChannel); ok {
+		logger.Info("Deleting message in channel", "msg_id", msgID)
+		_, err := api.ChannelsDeleteMessages(ctx, &tg.ChannelsDeleteMessagesRequest{
+			Channel: &tg.InputChannel{
+				ChannelID:  channelPeer.ChannelID,
+				AccessHash: channelPeer.AccessHash,
+			},
+			ID: []int{msgID},
+		})
+		if err != nil {
+			logger.Error("Failed to delete channel message", "error", err)
+		}
+		return
+	}
+
+	logger.Info("Deleting message in chat", "msg_id", msgID)
+	_, err := api.MessagesDeleteMessages(ctx, &tg.MessagesDeleteMessagesRequest{
+		ID:     []int{msgID},
+		Revoke: true,
+	})
+	if err != nil {
+		logger.Error("Failed to delete message", "error", err)
+	}
+}
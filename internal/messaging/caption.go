@@ -10,8 +10,11 @@ import (
 
 	"github.com/gotd/td/tg"
 	"github.com/pavelc4/aether-tg-bot/internal/provider"
+	"github.com/pavelc4/aether-tg-bot/internal/utils"
 )
 
+const maxAltLength = 300
+
 func BuildCaption(info provider.VideoInfo, providerName string, duration time.Duration, sourceURL string, userName string) string {
 	sizeMB := float64(info.FileSize) / 1024 / 1024
 
@@ -28,19 +31,33 @@ func BuildCaption(info provider.VideoInfo, providerName string, duration time.Du
 
 	srcLink := fmt.Sprintf(`<a href="%s">%s</a>`, sourceURL, safeProvider)
 
-	baseText := fmt.Sprintf("<b>%s</b>\n"+
-		"🔗 Source : %s\n"+
-		"💾 Size : <code>%.2f MB</code>\n"+
-		"⏱️ Processing Time : <code>%s</code>\n"+
-		"👤 By : %s",
-		safeTitle,
-		srcLink,
-		sizeMB,
-		duration.Round(time.Second),
-		safeUser,
-	)
-
-	return baseText
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<b>%s</b>\n", safeTitle))
+	if info.Author != "" {
+		sb.WriteString(fmt.Sprintf("✍️ Author : %s\n", html.EscapeString(info.Author)))
+	}
+	if alt := AltCaption(info); alt != "" {
+		sb.WriteString(fmt.Sprintf("🖼️ Alt : %s\n", html.EscapeString(alt)))
+	}
+	sb.WriteString(fmt.Sprintf("🔗 Source : %s\n", srcLink))
+	sb.WriteString(fmt.Sprintf("💾 Size : <code>%.2f MB</code>\n", sizeMB))
+	if info.Views > 0 || info.Likes > 0 {
+		sb.WriteString(fmt.Sprintf("📈 Stats : <code>%s views · %s likes</code>\n",
+			utils.FormatCount(info.Views), utils.FormatCount(info.Likes)))
+	}
+	sb.WriteString(fmt.Sprintf("⏱️ Processing Time : <code>%s</code>\n", duration.Round(time.Second)))
+	sb.WriteString(fmt.Sprintf("👤 By : %s", safeUser))
+
+	return sb.String()
+}
+
+// AltCaption returns the item's alt text shortened to fit a media caption.
+func AltCaption(info provider.VideoInfo) string {
+	alt := strings.TrimSpace(info.AltText)
+	if runes := []rune(alt); len(runes) > maxAltLength {
+		alt = string(runes[:maxAltLength-3]) + "..."
+	}
+	return alt
 }
 
 func ParseCaptionEntities(text string) (string, []tg.MessageEntityClass) {
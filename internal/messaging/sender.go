@@ -38,15 +38,51 @@ func (s *Sender) SendSingle(ctx context.Context, peer tg.InputPeerClass, replyTo
 	return updates, nil
 }
 
-func (s *Sender) SendAlbum(ctx context.Context, peer tg.InputPeerClass, replyTo tg.InputReplyToClass, batch []tg.InputMediaClass, batchInfos []provider.VideoInfo, providerName string, startTime time.Time, url string, userName string, albumIndex int, totalAlbumLen int, offset int) error {
+// SendFile sends a media item with a plain text caption.
+func (s *Sender) SendFile(ctx context.Context, peer tg.InputPeerClass, replyTo tg.InputReplyToClass, media tg.InputMediaClass, caption string) (tg.UpdatesClass, error) {
+	updates, err := s.api.MessagesSendMedia(ctx, &tg.MessagesSendMediaRequest{
+		Peer:     peer,
+		ReplyTo:  replyTo,
+		Media:    media,
+		Message:  caption,
+		RandomID: time.Now().UnixNano(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send file: %w", err)
+	}
+	return updates, nil
+}
+
+// SendCaption sends the caption on its own, for albums whose final batch
+// failed to download and so never carried it.
+func (s *Sender) SendCaption(ctx context.Context, peer tg.InputPeerClass, replyTo tg.InputReplyToClass, info provider.VideoInfo, providerName string, startTime time.Time, url string, userName string) error {
+	captionHTML := BuildCaption(info, providerName, time.Since(startTime), url, userName)
+	captionText, entities := ParseCaptionEntities(captionHTML)
+
+	_, err := s.api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+		Peer:     peer,
+		ReplyTo:  replyTo,
+		Message:  captionText,
+		Entities: entities,
+		RandomID: time.Now().UnixNano(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send caption: %w", err)
+	}
+	return nil
+}
+
+func (s *Sender) SendAlbum(ctx context.Context, peer tg.InputPeerClass, replyTo tg.InputReplyToClass, batch []tg.InputMediaClass, batchInfos []provider.VideoInfo, providerName string, startTime time.Time, url string, userName string, isFirstBatch bool, isLastBatch bool) error {
 	multiMedia, err := s.prepareAlbumHelper(ctx, batch)
 	if err != nil {
 		logger.Error("Failed to prepare album", "error", err)
 	}
 
 	if len(multiMedia) == len(batch) {
-		end := offset + len(batch)
-		isLastBatch := (end == totalAlbumLen)
+		// Items without the full caption still carry their own alt text
+		for k := range multiMedia {
+			multiMedia[k].Message = AltCaption(batchInfos[k])
+		}
 
 		// Add caption to the last item of the batch if it's the last batch of the album
 		if isLastBatch {
@@ -73,7 +109,7 @@ func (s *Sender) SendAlbum(ctx context.Context, peer tg.InputPeerClass, replyTo
 			"error", err.Error(),
 			"batch_size", len(batch),
 		)
-		s.sendIndividualFallback(ctx, peer, replyTo, batch, batchInfos, providerName, startTime, url, userName, albumIndex, totalAlbumLen, offset)
+		s.sendIndividualFallback(ctx, peer, replyTo, batch, batchInfos, providerName, startTime, url, userName, isFirstBatch, isLastBatch)
 	} else {
 		logger.Info("Successfully sent album", "items", len(batch))
 	}
@@ -81,11 +117,11 @@ func (s *Sender) SendAlbum(ctx context.Context, peer tg.InputPeerClass, replyTo
 	return nil
 }
 
-func (s *Sender) sendIndividualFallback(ctx context.Context, peer tg.InputPeerClass, replyTo tg.InputReplyToClass, batch []tg.InputMediaClass, batchInfos []provider.VideoInfo, providerName string, startTime time.Time, url string, userName string, albumIndex int, totalAlbumLen int, offset int) {
+func (s *Sender) sendIndividualFallback(ctx context.Context, peer tg.InputPeerClass, replyTo tg.InputReplyToClass, batch []tg.InputMediaClass, batchInfos []provider.VideoInfo, providerName string, startTime time.Time, url string, userName string, isFirstBatch bool, isLastBatch bool) {
 	const fallbackDelay = 500 * time.Millisecond
 	
 	for j, media := range batch {
-		isLastImage := (offset + j == totalAlbumLen-1)
+		isLastImage := isLastBatch && j == len(batch)-1
 		
 		var singleCaptionText string
 		var singleEntities []tg.MessageEntityClass
@@ -93,10 +129,12 @@ func (s *Sender) sendIndividualFallback(ctx context.Context, peer tg.InputPeerCl
 		if isLastImage {
 			captionHTML := BuildCaption(batchInfos[j], providerName, time.Since(startTime), url, userName)
 			singleCaptionText, singleEntities = ParseCaptionEntities(captionHTML)
+		} else {
+			singleCaptionText = AltCaption(batchInfos[j])
 		}
 
 		var singleReplyTo tg.InputReplyToClass
-		if albumIndex == 0 && j == 0 {
+		if isFirstBatch && j == 0 {
 			singleReplyTo = replyTo
 		}
 
@@ -0,0 +1,342 @@
+// Package plugin loads site support from external executables.
+//
+// A plugin is any executable file in the plugins directory. For every call
+// the bot starts the plugin, writes one JSON request to its stdin, closes
+// stdin and reads one JSON response from stdout:
+//
+//	{"method": "describe"}
+//	  -> {"name": "Example", "patterns": ["example\\.com/watch/"]}
+//	{"method": "resolve", "url": "https://...", "options": {"audio_only": false, "flags": {}}}
+//	  -> {"items": [{"url": "https://...", "filename": "a.mp4", "mime_type": "video/mp4"}]}
+//
+// A resolve item may carry "pipe": ["cmd", "arg", ...] instead of a URL; the
+// command's stdout is then streamed as the file. Any response may set
+// "error" to fail the call. Describe must return at least one pattern; URLs
+// are matched against them in the bot and the plugin is only started to
+// resolve.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/internal/provider"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const (
+	describeTimeout = 10 * time.Second
+	maxResponseSize = 10 * 1024 * 1024
+
+	// waitDelay bounds how long call waits for stdout to close after the
+	// plugin is killed, in case it left children holding the pipe
+	waitDelay = 2 * time.Second
+)
+
+type request struct {
+	Method  string         `json:"method"`
+	URL     string         `json:"url,omitempty"`
+	Options *requestOption `json:"options,omitempty"`
+}
+
+type requestOption struct {
+	AudioOnly bool              `json:"audio_only"`
+	Flags     map[string]string `json:"flags,omitempty"`
+}
+
+type response struct {
+	Error    string   `json:"error"`
+	Name     string   `json:"name"`
+	Patterns []string `json:"patterns"`
+	Items    []item   `json:"items"`
+}
+
+type item struct {
+	URL       string            `json:"url"`
+	Pipe      []string          `json:"pipe"`
+	FileName  string            `json:"filename"`
+	Title     string            `json:"title"`
+	Caption   string            `json:"caption"`
+	Author    string            `json:"author"`
+	Thumbnail string            `json:"thumbnail"`
+	FileSize  int64             `json:"size"`
+	MimeType  string            `json:"mime_type"`
+	Duration  int               `json:"duration"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Headers   map[string]string `json:"headers"`
+}
+
+// Plugin is a provider.Provider backed by an external executable.
+type Plugin struct {
+	path     string
+	name     string
+	patterns []*regexp.Regexp
+	timeout  time.Duration
+	slots    chan struct{}
+}
+
+var (
+	installedMu sync.RWMutex
+	installed   []*Plugin
+)
+
+// Load starts every executable in dir with a describe call and returns the
+// plugins that answered. Broken plugins are logged and skipped.
+func Load(ctx context.Context, dir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read plugins dir failed: %w", err)
+	}
+
+	var loaded []*Plugin
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Mode()&0111 == 0 {
+			continue
+		}
+
+		path, err := filepath.Abs(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		p, err := newPlugin(ctx, path)
+		if err != nil {
+			logger.Warn("Skipping plugin", "path", path, "error", err)
+			continue
+		}
+		logger.Info("Loaded plugin", "name", p.name, "path", path, "patterns", len(p.patterns))
+		loaded = append(loaded, p)
+	}
+	return loaded, nil
+}
+
+// Reload loads the plugins from the configured directory and installs them
+// in the provider registry, replacing the previous set.
+func Reload(ctx context.Context) ([]*Plugin, error) {
+	loaded, err := Load(ctx, config.GetPluginsDir())
+	if err != nil {
+		return nil, err
+	}
+
+	providers := make([]provider.Provider, len(loaded))
+	for i, p := range loaded {
+		providers[i] = p
+	}
+	provider.SetPlugins(providers)
+
+	installedMu.Lock()
+	installed = loaded
+	installedMu.Unlock()
+	return loaded, nil
+}
+
+// Installed returns the plugins currently registered as providers.
+func Installed() []*Plugin {
+	installedMu.RLock()
+	defer installedMu.RUnlock()
+	return append([]*Plugin(nil), installed...)
+}
+
+func newPlugin(ctx context.Context, path string) (*Plugin, error) {
+	p := &Plugin{
+		path:    path,
+		name:    strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		timeout: config.GetPluginTimeout(),
+		slots:   make(chan struct{}, config.GetPluginConcurrency()),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+
+	resp, err := p.call(ctx, request{Method: "describe"})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Name != "" {
+		p.name = resp.Name
+	}
+	// Supports runs for every URL message under the registry lock, so it
+	// must never have to start the plugin
+	if len(resp.Patterns) == 0 {
+		return nil, errors.New("describe returned no patterns")
+	}
+	for _, pattern := range resp.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		p.patterns = append(p.patterns, re)
+	}
+	return p, nil
+}
+
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Path returns the executable backing the plugin.
+func (p *Plugin) Path() string {
+	return p.path
+}
+
+// Patterns returns the number of URL patterns the plugin declared.
+func (p *Plugin) Patterns() int {
+	return len(p.patterns)
+}
+
+func (p *Plugin) Supports(url string) bool {
+	for _, re := range p.patterns {
+		if re.MatchString(url) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Plugin) GetVideoInfo(ctx context.Context, url string, opts provider.Options) ([]provider.VideoInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resp, err := p.call(ctx, request{
+		Method:  "resolve",
+		URL:     url,
+		Options: &requestOption{AudioOnly: opts.AudioOnly, Flags: opts.Flags},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]provider.VideoInfo, 0, len(resp.Items))
+	for _, it := range resp.Items {
+		info := provider.VideoInfo{
+			URL:       it.URL,
+			FileName:  it.FileName,
+			Title:     it.Title,
+			Caption:   it.Caption,
+			Author:    it.Author,
+			Thumbnail: it.Thumbnail,
+			FileSize:  it.FileSize,
+			MimeType:  it.MimeType,
+			Duration:  it.Duration,
+			Width:     it.Width,
+			Height:    it.Height,
+			Headers:   it.Headers,
+		}
+		if len(it.Pipe) > 0 {
+			info.PipeCommand = p.resolveCommand(it.Pipe)
+			if info.URL == "" {
+				// Resolve treats an empty URL as "no media"
+				info.URL = url
+			}
+		}
+		if info.URL == "" {
+			continue
+		}
+		if info.FileName == "" {
+			info.FileName = p.name + "_media"
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// resolveCommand makes a "./helper" style command relative to the plugins
+// directory rather than the bot's working directory.
+func (p *Plugin) resolveCommand(argv []string) []string {
+	cmd := append([]string(nil), argv...)
+	if strings.HasPrefix(cmd[0], "./") || strings.HasPrefix(cmd[0], "../") {
+		cmd[0] = filepath.Join(filepath.Dir(p.path), cmd[0])
+	}
+	return cmd
+}
+
+// call runs the plugin once. Each call is its own process, so a plugin that
+// crashes or hangs only fails the request that triggered it.
+func (p *Plugin) call(ctx context.Context, req request) (*response, error) {
+	select {
+	case p.slots <- struct{}{}:
+		defer func() { <-p.slots }()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("plugin %s busy: %w", p.name, ctx.Err())
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	payload = append(payload, '\n')
+
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Dir = filepath.Dir(p.path)
+	cmd.WaitDelay = waitDelay
+	cmd.Stdin = bytes.NewReader(payload)
+	var stderr bytes.Buffer
+	cmd.Stderr = &limitedBuffer{buf: &stderr, limit: 4096}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start plugin %s failed: %w", p.name, err)
+	}
+
+	out, readErr := io.ReadAll(io.LimitReader(stdout, maxResponseSize))
+	waitErr := cmd.Wait()
+
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("plugin %s timed out: %w", p.name, ctx.Err())
+	}
+	if waitErr != nil {
+		return nil, fmt.Errorf("plugin %s exited: %w (stderr: %s)", p.name, waitErr, strings.TrimSpace(stderr.String()))
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("read plugin %s output failed: %w", p.name, readErr)
+	}
+
+	var resp response
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s returned invalid JSON: %w", p.name, err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest.
+type limitedBuffer struct {
+	buf   *bytes.Buffer
+	limit int
+}
+
+func (l *limitedBuffer) Write(b []byte) (int, error) {
+	if room := l.limit - l.buf.Len(); room > 0 {
+		if len(b) > room {
+			l.buf.Write(b[:room])
+		} else {
+			l.buf.Write(b)
+		}
+	}
+	return len(b), nil
+}
@@ -0,0 +1,224 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+)
+
+const (
+	blueskyTimeout = 30 * time.Second
+	blueskyAPIURL  = "https://public.api.bsky.app/xrpc"
+)
+
+var blueskyPostRegex = regexp.MustCompile(`bsky\.app/profile/([^/?#]+)/post/(\w+)`)
+
+type BlueskyProvider struct {
+	client *http.Client
+}
+
+func NewBluesky() *BlueskyProvider {
+	return &BlueskyProvider{
+		client: &http.Client{
+			Timeout: blueskyTimeout,
+		},
+	}
+}
+
+func (bp *BlueskyProvider) Name() string {
+	return "Bluesky"
+}
+
+func (bp *BlueskyProvider) Supports(url string) bool {
+	return strings.Contains(url, "bsky.app/")
+}
+
+func (bp *BlueskyProvider) GetVideoInfo(ctx context.Context, rawURL string, opts Options) ([]VideoInfo, error) {
+	m := blueskyPostRegex.FindStringSubmatch(rawURL)
+	if m == nil {
+		return nil, ErrUnsupported
+	}
+	actor, rkey := m[1], m[2]
+
+	if !strings.HasPrefix(actor, "did:") {
+		did, err := bp.resolveHandle(ctx, actor)
+		if err != nil {
+			return nil, err
+		}
+		actor = did
+	}
+
+	post, err := bp.fetchPost(ctx, fmt.Sprintf("at://%s/app.bsky.feed.post/%s", actor, rkey))
+	if err != nil {
+		return nil, err
+	}
+
+	base := VideoInfo{
+		Title:   post.Record.Text,
+		Caption: post.Record.Text,
+		Author:  post.Author.Handle,
+		Likes:   post.LikeCount,
+	}
+	if post.Author.DisplayName != "" {
+		base.Author = fmt.Sprintf("%s (@%s)", post.Author.DisplayName, post.Author.Handle)
+	}
+	if base.Title == "" {
+		base.Title = "Post by @" + post.Author.Handle
+	}
+
+	embed := post.Embed
+	if embed.Media != nil {
+		// recordWithMedia: a quote post with its own attachments
+		embed = *embed.Media
+	}
+
+	var results []VideoInfo
+	switch {
+	case len(embed.Images) > 0:
+		if opts.AudioOnly {
+			return nil, ErrUnsupported
+		}
+		for i, img := range embed.Images {
+			info := base
+			info.URL = img.Fullsize
+			info.Thumbnail = img.Thumb
+			info.AltText = img.Alt
+			info.Width = img.AspectRatio.Width
+			info.Height = img.AspectRatio.Height
+			info.FileName = fmt.Sprintf("bsky_%s_%d.jpg", rkey, i)
+			info.MimeType = "image/jpeg"
+			results = append(results, info)
+		}
+
+	case embed.Playlist != "":
+		info := base
+		info.URL = embed.Playlist
+		info.Thumbnail = embed.Thumbnail
+		info.AltText = embed.Alt
+		info.Width = embed.AspectRatio.Width
+		info.Height = embed.AspectRatio.Height
+		info.FileName = fmt.Sprintf("bsky_%s.mp4", rkey)
+		info.MimeType = "video/mp4"
+		// Remux the HLS segments into fragmented MP4; piped as-is they
+		// would be MPEG-TS
+		info.Mux = &MuxSpec{Inputs: []string{embed.Playlist}, Mode: "copy"}
+		if opts.AudioOnly {
+			info.FileName = fmt.Sprintf("bsky_%s.m4a", rkey)
+			info.MimeType = "audio/mp4"
+			info.Mux = &MuxSpec{Inputs: []string{embed.Playlist}, Mode: "audio", AudioCopy: true}
+		}
+		results = append(results, info)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no media found in post %s", rkey)
+	}
+	return results, nil
+}
+
+func (bp *BlueskyProvider) resolveHandle(ctx context.Context, handle string) (string, error) {
+	var result struct {
+		DID string `json:"did"`
+	}
+	if err := bp.call(ctx, "com.atproto.identity.resolveHandle", url.Values{"handle": {handle}}, &result); err != nil {
+		return "", err
+	}
+	if result.DID == "" {
+		return "", ErrContentUnavailable
+	}
+	return result.DID, nil
+}
+
+func (bp *BlueskyProvider) fetchPost(ctx context.Context, uri string) (*blueskyPost, error) {
+	var result struct {
+		Thread struct {
+			Type string       `json:"$type"`
+			Post *blueskyPost `json:"post"`
+		} `json:"thread"`
+	}
+	params := url.Values{"uri": {uri}, "depth": {"0"}, "parentHeight": {"0"}}
+	if err := bp.call(ctx, "app.bsky.feed.getPostThread", params, &result); err != nil {
+		return nil, err
+	}
+
+	switch result.Thread.Type {
+	case "app.bsky.feed.defs#blockedPost":
+		return nil, ErrContentPrivate
+	case "app.bsky.feed.defs#notFoundPost":
+		return nil, ErrContentUnavailable
+	}
+	if result.Thread.Post == nil {
+		return nil, ErrContentUnavailable
+	}
+	return result.Thread.Post, nil
+}
+
+func (bp *BlueskyProvider) call(ctx context.Context, method string, params url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", blueskyAPIURL+"/"+method+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := bp.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusNotFound:
+		// XRPC reports unknown handles and deleted posts as 400
+		return ErrContentUnavailable
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return errs.Status("bluesky API", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode failed: %w", err)
+	}
+	return nil
+}
+
+type blueskyPost struct {
+	Author struct {
+		Handle      string `json:"handle"`
+		DisplayName string `json:"displayName"`
+	} `json:"author"`
+	Record struct {
+		Text string `json:"text"`
+	} `json:"record"`
+	Embed     blueskyEmbed `json:"embed"`
+	LikeCount int64        `json:"likeCount"`
+}
+
+// blueskyEmbed covers the images, video and recordWithMedia embed views.
+type blueskyEmbed struct {
+	Type   string `json:"$type"`
+	Images []struct {
+		Thumb       string             `json:"thumb"`
+		Fullsize    string             `json:"fullsize"`
+		Alt         string             `json:"alt"`
+		AspectRatio blueskyAspectRatio `json:"aspectRatio"`
+	} `json:"images"`
+	Playlist    string             `json:"playlist"`
+	Thumbnail   string             `json:"thumbnail"`
+	Alt         string             `json:"alt"`
+	AspectRatio blueskyAspectRatio `json:"aspectRatio"`
+	Media       *blueskyEmbed      `json:"media"`
+}
+
+type blueskyAspectRatio struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// chapterAudioTypes maps yt-dlp audio extensions to MIME types that
+// download.startMux can write to a pipe without re-encoding.
+var chapterAudioTypes = map[string]string{
+	"m4a":  "audio/mp4",
+	"mp4":  "audio/mp4",
+	"webm": "audio/ogg",
+	"opus": "audio/ogg",
+	"ogg":  "audio/ogg",
+	"mp3":  "audio/mpeg",
+}
+
+var chapterAudioExts = map[string]string{
+	"audio/mp4":  "m4a",
+	"audio/ogg":  "ogg",
+	"audio/mpeg": "mp3",
+}
+
+// chapterTracks turns the chapters of an audio-only yt-dlp result into one
+// track per chapter. Each track is cut from the audio stream with a seeked
+// ffmpeg input and tagged with title, artist, album and track number.
+func chapterTracks(meta ytdlpMeta) []VideoInfo {
+	if len(meta.Chapters) < 2 || meta.URL == "" {
+		return nil
+	}
+	mime, ok := chapterAudioTypes[meta.Ext]
+	if !ok {
+		return nil
+	}
+
+	total := len(meta.Chapters)
+	tracks := make([]VideoInfo, 0, total)
+	for i, ch := range meta.Chapters {
+		end := ch.EndTime
+		if end <= 0 && i+1 < total {
+			end = meta.Chapters[i+1].StartTime
+		}
+		if end <= 0 {
+			end = meta.Duration
+		}
+		length := end - ch.StartTime
+		if length <= 0 {
+			continue
+		}
+
+		title := strings.TrimSpace(ch.Title)
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		track := strconv.Itoa(i+1) + "/" + strconv.Itoa(total)
+		name := strings.NewReplacer("/", "_", "\\", "_").Replace(title)
+
+		tracks = append(tracks, VideoInfo{
+			URL:      meta.URL,
+			FileName: fmt.Sprintf("%02d - %s.%s", i+1, name, chapterAudioExts[mime]),
+			Title:    title,
+			Author:   meta.Uploader,
+			MimeType: mime,
+			Duration: int(length),
+			Headers:  meta.HttpHeaders,
+			Mux: &MuxSpec{
+				Inputs:      []string{meta.URL},
+				Mode:        "audio",
+				AudioCopy:   true,
+				Start:       int(ch.StartTime),
+				MaxDuration: int(length + 0.5),
+				Metadata: map[string]string{
+					"title":  title,
+					"artist": meta.Uploader,
+					"album":  meta.Title,
+					"track":  track,
+				},
+			},
+		})
+	}
+	return tracks
+}
@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+)
+
+var ErrClipRange = errs.New(errs.Unsupported, "the clip is outside the video")
+
+// applyClip limits resolved items to clip. yt-dlp items cut with
+// --download-sections when they are piped; every other item goes through
+// ffmpeg with its inputs seeked to the start.
+func applyClip(infos []VideoInfo, clip Clip) ([]VideoInfo, error) {
+	var out []VideoInfo
+	for _, info := range infos {
+		mime := info.MimeType
+		if mime == "" {
+			mime = guessMimeType(info.FileName)
+			info.MimeType = mime
+		}
+		if !strings.HasPrefix(mime, "video/") && !strings.HasPrefix(mime, "audio/") {
+			continue
+		}
+
+		// Capped per item, so a short item does not shorten the ones after it
+		c := clip
+		if info.Duration > 0 {
+			total := float64(info.Duration)
+			if c.Start.Seconds() >= total {
+				return nil, fmt.Errorf("%w: starts at %ds but it is %ds long", ErrClipRange, int(c.Start.Seconds()), info.Duration)
+			}
+			if c.End.Seconds() > total {
+				c.End = time.Duration(info.Duration) * time.Second
+			}
+		}
+
+		info.Duration = int(c.Length().Seconds())
+		ext := filepath.Ext(info.FileName)
+		info.FileName = strings.TrimSuffix(info.FileName, ext) + fmt.Sprintf("_clip_%d-%d", int(c.Start.Seconds()), int(c.End.Seconds())) + ext
+		info.FileSize = 0 // Unknown until cut
+
+		switch {
+		case info.UsePipe:
+			info.Clip = &c
+		case len(info.PipeCommand) > 0:
+			// Plugin commands cannot be cut from outside
+			return nil, fmt.Errorf("%w: this source cannot be clipped", ErrUnsupported)
+		default:
+			if info.Mux == nil {
+				info.Mux = &MuxSpec{Inputs: []string{info.URL}, Mode: "copy"}
+				if strings.HasPrefix(mime, "audio/") {
+					info.Mux.Mode = "audio"
+				}
+			}
+			if !muxableMime(mime) {
+				info.MimeType = "video/mp4"
+				if strings.HasPrefix(mime, "audio/") {
+					info.MimeType = "audio/mp4"
+				}
+			}
+			spec := *info.Mux
+			spec.Start = int(c.Start.Seconds())
+			spec.MaxDuration = int(c.Length().Seconds())
+			info.Mux = &spec
+		}
+		out = append(out, info)
+	}
+
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: nothing to clip", ErrUnsupported)
+	}
+	return out, nil
+}
+
+// muxableMime lists the outputs download.startMux can write to a pipe.
+func muxableMime(mime string) bool {
+	switch mime {
+	case "video/mp4", "video/webm", "video/x-matroska", "audio/mp4", "audio/mpeg", "audio/ogg", "audio/opus", "audio/wav":
+		return true
+	}
+	return false
+}
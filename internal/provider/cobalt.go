@@ -7,17 +7,21 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+	"github.com/pavelc4/aether-tg-bot/pkg/mediatype"
 )
 
 const cobaltTimeout = 30 * time.Second
 
 type CobaltProvider struct {
 	client *http.Client
+	pool   *cobaltPool
 }
 
 func NewCobalt() *CobaltProvider {
@@ -25,6 +29,7 @@ func NewCobalt() *CobaltProvider {
 		client: &http.Client{
 			Timeout: cobaltTimeout,
 		},
+		pool: newCobaltPool(config.GetCobaltInstances()),
 	}
 }
 
@@ -81,12 +86,74 @@ func (cp *CobaltProvider) GetVideoInfo(ctx context.Context, url string, opts Opt
 	return cp.parseResponse(apiResp)
 }
 
+// Probe checks every Cobalt instance's info endpoint and succeeds if at
+// least one of them answers.
+func (cp *CobaltProvider) Probe(ctx context.Context) error {
+	var lastErr error
+	for _, inst := range cp.pool.instances {
+		err := cp.probeInstance(ctx, inst)
+		if err == nil {
+			cp.pool.markSuccess(inst)
+			return nil
+		}
+		cp.pool.markFailure(inst, err, time.Now().Add(cobaltInstanceCooldown))
+		lastErr = err
+	}
+	if lastErr == nil {
+		return fmt.Errorf("no cobalt instances configured")
+	}
+	return lastErr
+}
+
+func (cp *CobaltProvider) probeInstance(ctx context.Context, inst *cobaltInstance) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", inst.cfg.URL, nil)
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := cp.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cobalt request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errs.Status("cobalt", resp.StatusCode)
+	}
+	return nil
+}
+
+func (cp *CobaltProvider) InstanceStats() []InstanceStats {
+	return cp.pool.stats()
+}
+
 type cobaltAPIResponse struct {
-	Status   string        `json:"status"`
-	URL      string        `json:"url"`
-	Filename string        `json:"filename"`
-	Picker   []cobaltItem  `json:"picker"`
-	Error    cobaltError   `json:"error"`
+	Status   string       `json:"status"`
+	URL      string       `json:"url"`
+	Filename string       `json:"filename"`
+	Picker   []cobaltItem `json:"picker"`
+	Error    cobaltError  `json:"error"`
+
+	// local-processing fields
+	Type   string       `json:"type"`
+	Tunnel []string     `json:"tunnel"`
+	Output cobaltOutput `json:"output"`
+	Audio  cobaltAudio  `json:"audio"`
+	IsHLS  bool         `json:"isHLS"`
+}
+
+type cobaltOutput struct {
+	Type      string            `json:"type"`
+	Filename  string            `json:"filename"`
+	Metadata  map[string]string `json:"metadata"`
+	Subtitles bool              `json:"subtitles"`
+}
+
+type cobaltAudio struct {
+	Copy    bool   `json:"copy"`
+	Format  string `json:"format"`
+	Bitrate string `json:"bitrate"`
 }
 
 type cobaltItem struct {
@@ -100,56 +167,172 @@ type cobaltError struct {
 	Context string `json:"context"`
 }
 
-func (cp *CobaltProvider) requestAPI(ctx context.Context, mediaURL string, opts Options) (*cobaltAPIResponse, error) {
+// cobaltFlags maps command flags to the Cobalt request options they
+// override.
+var cobaltFlags = map[string]struct {
+	key    string
+	isBool bool
+}{
+	"codec":             {key: "youtubeVideoCodec"},
+	"audio-format":      {key: "audioFormat"},
+	"audio-bitrate":     {key: "audioBitrate"},
+	"filename-style":    {key: "filenameStyle"},
+	"dub-lang":          {key: "youtubeDubLang"},
+	"subs":              {key: "subtitleLang"},
+	"tiktok-full-audio": {key: "tiktokFullAudio", isBool: true},
+	"always-proxy":      {key: "alwaysProxy", isBool: true},
+	"disable-metadata":  {key: "disableMetadata", isBool: true},
+	"twitter-gif":       {key: "twitterGif", isBool: true},
+}
+
+func buildCobaltRequest(mediaURL string, opts Options) map[string]interface{} {
+	defaults := config.GetCobaltOptions()
 	requestBody := map[string]interface{}{
-		"url":          mediaURL,
-		"downloadMode": "auto",
-		"videoQuality": "max",
+		"url":             mediaURL,
+		"downloadMode":    "auto",
+		"videoQuality":    "max",
+		"tiktokFullAudio": defaults.TiktokFullAudio,
+		"alwaysProxy":     defaults.AlwaysProxy,
+		"disableMetadata": defaults.DisableMetadata,
+		"twitterGif":      defaults.TwitterGif,
 	}
-	
+
+	for key, value := range map[string]string{
+		"youtubeVideoCodec": defaults.YoutubeVideoCodec,
+		"audioFormat":       defaults.AudioFormat,
+		"audioBitrate":      defaults.AudioBitrate,
+		"filenameStyle":     defaults.FilenameStyle,
+		"youtubeDubLang":    defaults.YoutubeDubLang,
+	} {
+		if value != "" {
+			requestBody[key] = value
+		}
+	}
+
+	for flag, value := range opts.Flags {
+		opt, ok := cobaltFlags[flag]
+		if !ok {
+			continue
+		}
+		if opt.isBool {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				logger.Warn("Ignoring invalid cobalt flag", "flag", flag, "value", value)
+				continue
+			}
+			requestBody[opt.key] = b
+			continue
+		}
+		if !config.IsValidCobaltOption(opt.key, value) {
+			logger.Warn("Ignoring invalid cobalt flag", "flag", flag, "value", value, "allowed", config.CobaltOptionValues[opt.key])
+			continue
+		}
+		requestBody[opt.key] = value
+	}
+
 	if opts.AudioOnly {
 		requestBody["downloadMode"] = "audio"
 		requestBody["isAudioOnly"] = true
 	}
 
+	return requestBody
+}
+
+func (cp *CobaltProvider) requestAPI(ctx context.Context, mediaURL string, opts Options) (*cobaltAPIResponse, error) {
+	requestBody := buildCobaltRequest(mediaURL, opts)
+
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("marshal request failed: %w", err)
 	}
 
-	apiURL := config.GetCobaltAPI()
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonBody))
+	tried := make(map[*cobaltInstance]bool, cp.pool.size())
+	var lastErr error
+	for {
+		inst := cp.pool.next(tried)
+		if inst == nil {
+			break
+		}
+		tried[inst] = true
+
+		resp, retry, err := cp.requestInstance(ctx, inst, jsonBody)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			return nil, err
+		}
+		logger.Warn("Cobalt instance failed, trying next", "instance", inst.cfg.URL, "error", err)
+	}
+
+	if lastErr == nil {
+		return nil, fmt.Errorf("no cobalt instances configured")
+	}
+	return nil, lastErr
+}
+
+// requestInstance posts the request to a single instance. The returned bool
+// reports whether the failure is worth retrying on another instance.
+func (cp *CobaltProvider) requestInstance(ctx context.Context, inst *cobaltInstance, jsonBody []byte) (*cobaltAPIResponse, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", inst.cfg.URL, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return nil, fmt.Errorf("create request failed: %w", err)
+		return nil, false, fmt.Errorf("create request failed: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	if apiKey := config.GetCobaltAPIKey(); apiKey != "" {
-		req.Header.Set("Authorization", "Api-Key "+apiKey)
+	if inst.cfg.Bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+inst.cfg.Bearer)
+	} else if inst.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Api-Key "+inst.cfg.APIKey)
 	}
 
 	resp, err := cp.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("cobalt request failed: %w", err)
+		err = errs.Network("cobalt request", err)
+		cp.pool.markFailure(inst, err, time.Now().Add(cobaltInstanceCooldown))
+		return nil, true, err
 	}
 	defer resp.Body.Close()
 
+	cp.pool.recordQuota(inst, resp.Header)
+
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read response failed: %w", err)
+		err = fmt.Errorf("read response failed: %w", err)
+		cp.pool.markFailure(inst, err, time.Now().Add(cobaltInstanceCooldown))
+		return nil, true, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("cobalt returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		logger.Warn("Cobalt server error", "instance", inst.cfg.URL, "status", resp.StatusCode, "body", string(bodyBytes))
+		err := errs.Status("cobalt", resp.StatusCode)
+		cp.pool.markFailure(inst, err, time.Now().Add(cobaltInstanceCooldown))
+		return nil, true, err
 	}
 
 	var cobaltResponse cobaltAPIResponse
 	if err := json.Unmarshal(bodyBytes, &cobaltResponse); err != nil {
-		return nil, fmt.Errorf("decode response failed: %w", err)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			cp.pool.markFailure(inst, ErrRateLimited, cp.pool.rateLimitedUntil(inst))
+			return nil, true, ErrRateLimited
+		}
+		if resp.StatusCode != http.StatusOK {
+			logger.Warn("Cobalt returned non-JSON error", "instance", inst.cfg.URL, "status", resp.StatusCode, "body", string(bodyBytes))
+			return nil, false, errs.Status("cobalt", resp.StatusCode)
+		}
+		return nil, false, fmt.Errorf("decode response failed: %w", err)
 	}
 
-	return &cobaltResponse, nil
+	if resp.StatusCode == http.StatusTooManyRequests || cobaltResponse.Error.Code == "error.api.rate_exceeded" {
+		err := ErrRateLimited
+		cp.pool.markFailure(inst, err, cp.pool.rateLimitedUntil(inst))
+		return nil, true, err
+	}
+
+	cp.pool.markSuccess(inst)
+	return &cobaltResponse, false, nil
 }
 
 func (cp *CobaltProvider) parseResponse(resp *cobaltAPIResponse) ([]VideoInfo, error) {
@@ -201,36 +384,82 @@ func (cp *CobaltProvider) parseResponse(resp *cobaltAPIResponse) ([]VideoInfo, e
 
 		return results, nil
 
+	case "local-processing":
+		return cp.parseLocalProcessing(resp)
+
 	case "error":
-		return nil, fmt.Errorf("cobalt API error: %s (%s)", resp.Error.Code, resp.Error.Context)
+		logger.Warn("Cobalt API error", "code", resp.Error.Code, "context", resp.Error.Context)
+		return nil, newCobaltError(resp.Error.Code, resp.Error.Context)
 
 	default:
 		return nil, fmt.Errorf("unknown cobalt status: %s", resp.Status)
 	}
 }
 
-var mimeTypes = map[string]string{
-	".mp4":  "video/mp4",
-	".webm": "video/webm",
-	".mkv":  "video/x-matroska",
-	".mp3":  "audio/mpeg",
-	".m4a":  "audio/mp4",
-	".ogg":  "audio/ogg",
-	".wav":  "audio/wav",
-	".opus": "audio/opus",
-	".flac": "audio/flac",
-	".jpg":  "image/jpeg",
-	".jpeg": "image/jpeg",
-	".png":  "image/png",
-}
+// parseLocalProcessing handles responses where Cobalt hands out the raw
+// tunnels and expects the client to merge them (e.g. separate video and
+// audio streams).
+func (cp *CobaltProvider) parseLocalProcessing(resp *cobaltAPIResponse) ([]VideoInfo, error) {
+	if len(resp.Tunnel) == 0 {
+		return nil, fmt.Errorf("empty tunnel list in cobalt response")
+	}
 
-func guessMimeType(filename string) string {
-	ext := strings.ToLower(filepath.Ext(filename))
-	if idx := strings.Index(ext, "?"); idx != -1 {
-		ext = ext[:idx]
+	switch resp.Type {
+	case "merge", "mute", "audio", "gif", "remux", "proxy":
+	default:
+		return nil, fmt.Errorf("unknown cobalt local-processing type: %s", resp.Type)
+	}
+
+	if resp.Type == "merge" && len(resp.Tunnel) < 2 {
+		return nil, fmt.Errorf("merge requires video and audio tunnels, got %d", len(resp.Tunnel))
 	}
 
-	if mime, ok := mimeTypes[ext]; ok {
+	filename := resp.Output.Filename
+	if filename == "" {
+		filename = fmt.Sprintf("cobalt_%d.mp4", time.Now().Unix())
+	}
+
+	mime := resp.Output.Type
+	if mime == "" {
+		mime = guessMimeType(filename)
+	}
+
+	title := filename
+	if t := resp.Output.Metadata["title"]; t != "" {
+		title = t
+	}
+
+	if resp.Type == "proxy" {
+		// Nothing to merge: the single tunnel is the file
+		return []VideoInfo{{
+			URL:      resp.Tunnel[0],
+			FileName: filename,
+			Title:    title,
+			MimeType: mime,
+		}}, nil
+	}
+
+	return []VideoInfo{{
+		URL:      resp.Tunnel[0],
+		FileName: filename,
+		Title:    title,
+		MimeType: mime,
+		Mux: &MuxSpec{
+			Inputs:       resp.Tunnel,
+			Mode:         resp.Type,
+			AudioFormat:  resp.Audio.Format,
+			AudioBitrate: resp.Audio.Bitrate,
+			AudioCopy:    resp.Audio.Copy,
+			Subtitles:    resp.Output.Subtitles,
+			Metadata:     resp.Output.Metadata,
+		},
+	}}, nil
+}
+
+// guessMimeType is the declared type of a file before any of it is read;
+// the upload pipeline sniffs the real type from the first chunk.
+func guessMimeType(filename string) string {
+	if mime := mediatype.ByExtension(filename); mime != "" {
 		return mime
 	}
 	return "application/octet-stream"
@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+)
+
+const cobaltInstanceCooldown = 30 * time.Second
+
+type cobaltInstance struct {
+	cfg            config.CobaltInstance
+	currentWeight  int
+	requests       int64
+	failures       int64
+	lastError      string
+	lastErrorAt    time.Time
+	unhealthyUntil time.Time
+	rateLimit      int
+	rateRemaining  int
+	rateReset      time.Time
+}
+
+// cobaltPool spreads requests across Cobalt instances using smooth weighted
+// round-robin and keeps per-instance health and quota counters.
+type cobaltPool struct {
+	mu        sync.Mutex
+	instances []*cobaltInstance
+}
+
+func newCobaltPool(cfgs []config.CobaltInstance) *cobaltPool {
+	pool := &cobaltPool{}
+	for _, cfg := range cfgs {
+		if cfg.Weight <= 0 {
+			cfg.Weight = 1
+		}
+		pool.instances = append(pool.instances, &cobaltInstance{cfg: cfg})
+	}
+	return pool
+}
+
+func (p *cobaltPool) size() int {
+	return len(p.instances)
+}
+
+// next picks the next instance that has not been tried yet. Instances that
+// are cooling down are only used when nothing else has been tried.
+func (p *cobaltPool) next(tried map[*cobaltInstance]bool) *cobaltInstance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if inst := p.pick(tried, now, false); inst != nil {
+		return inst
+	}
+	if len(tried) == 0 {
+		return p.pick(tried, now, true)
+	}
+	return nil
+}
+
+func (p *cobaltPool) pick(tried map[*cobaltInstance]bool, now time.Time, includeCooling bool) *cobaltInstance {
+	var best *cobaltInstance
+	total := 0
+	for _, inst := range p.instances {
+		if tried[inst] {
+			continue
+		}
+		if !includeCooling && now.Before(inst.unhealthyUntil) {
+			continue
+		}
+		inst.currentWeight += inst.cfg.Weight
+		total += inst.cfg.Weight
+		if best == nil || inst.currentWeight > best.currentWeight {
+			best = inst
+		}
+	}
+	if best != nil {
+		best.currentWeight -= total
+	}
+	return best
+}
+
+func (p *cobaltPool) markSuccess(inst *cobaltInstance) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	inst.requests++
+	inst.unhealthyUntil = time.Time{}
+}
+
+func (p *cobaltPool) markFailure(inst *cobaltInstance, err error, cooldownUntil time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	inst.requests++
+	inst.failures++
+	inst.lastError = err.Error()
+	inst.lastErrorAt = time.Now()
+	if cooldownUntil.After(inst.unhealthyUntil) {
+		inst.unhealthyUntil = cooldownUntil
+	}
+}
+
+// recordQuota stores the RateLimit-* headers Cobalt sends with every
+// response.
+func (p *cobaltPool) recordQuota(inst *cobaltInstance, header http.Header) {
+	limit, errLimit := strconv.Atoi(header.Get("RateLimit-Limit"))
+	remaining, errRemaining := strconv.Atoi(header.Get("RateLimit-Remaining"))
+	if errLimit != nil || errRemaining != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	inst.rateLimit = limit
+	inst.rateRemaining = remaining
+	if reset, err := strconv.Atoi(header.Get("RateLimit-Reset")); err == nil {
+		inst.rateReset = time.Now().Add(time.Duration(reset) * time.Second)
+	}
+}
+
+// rateLimitedUntil returns when the instance quota resets, or the default
+// cooldown if Cobalt did not tell us.
+func (p *cobaltPool) rateLimitedUntil(inst *cobaltInstance) time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if inst.rateReset.After(time.Now()) {
+		return inst.rateReset
+	}
+	return time.Now().Add(cobaltInstanceCooldown)
+}
+
+func (p *cobaltPool) stats() []InstanceStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	result := make([]InstanceStats, 0, len(p.instances))
+	for _, inst := range p.instances {
+		result = append(result, InstanceStats{
+			URL:           inst.cfg.URL,
+			Weight:        inst.cfg.Weight,
+			Healthy:       !now.Before(inst.unhealthyUntil),
+			Requests:      inst.requests,
+			Failures:      inst.failures,
+			LastError:     inst.lastError,
+			LastErrorAt:   inst.lastErrorAt,
+			RateLimit:     inst.rateLimit,
+			RateRemaining: inst.rateRemaining,
+			RateReset:     inst.rateReset,
+		})
+	}
+	return result
+}
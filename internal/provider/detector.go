@@ -14,8 +14,10 @@ func ExtractURL(text string) string {
 }
 
 
-func IsSupported(url string) bool {
-	provider, err := GetProvider(url)
+// IsSupported reports whether a provider handles url. Fallback claims only
+// count when explicit is set.
+func IsSupported(url string, explicit bool) bool {
+	provider, err := GetProvider(url, explicit)
 	return err == nil && provider != nil
 }
 
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	pkghttp "github.com/pavelc4/aether-tg-bot/pkg/http"
+)
+
+const directTimeout = 20 * time.Second
+
+// directTypes lists the file extensions DirectProvider claims and the MIME
+// type used when the server only reports application/octet-stream.
+var directTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".opus": "audio/opus",
+	".flac": "audio/flac",
+	".wav":  "audio/wav",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".pdf":  "application/pdf",
+	".epub": "application/epub+zip",
+	".zip":  "application/zip",
+	".rar":  "application/vnd.rar",
+	".7z":   "application/x-7z-compressed",
+	".gz":   "application/gzip",
+	".tar":  "application/x-tar",
+	".apk":  "application/vnd.android.package-archive",
+	".txt":  "text/plain",
+	".srt":  "application/x-subrip",
+}
+
+// DirectProvider is the last-resort provider for plain links to files. It
+// only runs for /dl, since any link ending in .zip or .mp4 looks alike.
+type DirectProvider struct {
+	client *http.Client
+}
+
+func NewDirect() *DirectProvider {
+	return &DirectProvider{
+		client: &http.Client{
+			Timeout:   directTimeout,
+			Transport: &http.Transport{DialContext: pkghttp.PublicDialContext(directTimeout)},
+		},
+	}
+}
+
+func (dp *DirectProvider) Name() string {
+	return "Direct"
+}
+
+func (dp *DirectProvider) Supports(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	_, ok := directTypes[strings.ToLower(path.Ext(u.Path))]
+	return ok
+}
+
+// Fallback reports true for every link: direct files are only fetched when
+// asked for with /dl.
+func (dp *DirectProvider) Fallback(rawURL string) bool {
+	return true
+}
+
+func (dp *DirectProvider) GetVideoInfo(ctx context.Context, rawURL string, opts Options) ([]VideoInfo, error) {
+	resp, err := dp.probe(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, ErrContentUnavailable
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, ErrContentPrivate
+	default:
+		return nil, errs.Status("direct link", resp.StatusCode)
+	}
+
+	finalURL := resp.Request.URL
+	fileName := directFileName(resp.Header.Get("Content-Disposition"), finalURL)
+	ext := strings.ToLower(path.Ext(fileName))
+
+	mimeType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimeType == "" || mimeType == "application/octet-stream" || mimeType == "binary/octet-stream" {
+		mimeType = directTypes[ext]
+	}
+	if !isDirectMime(mimeType) {
+		return nil, ErrUnsupported
+	}
+
+	size := directSize(resp)
+	if limit := config.GetMaxFileSize(); size > limit {
+		return nil, fmt.Errorf("%w: %.1f MB exceeds %d MB", ErrTooLarge, float64(size)/1024/1024, limit/1024/1024)
+	}
+
+	return []VideoInfo{{
+		URL:        finalURL.String(),
+		FileName:   fileName,
+		Title:      fileName,
+		FileSize:   size,
+		MimeType:   mimeType,
+		AsDocument: true,
+		PublicOnly: true,
+	}}, nil
+}
+
+// probe asks for the headers with HEAD, falling back to a one-byte ranged
+// GET for servers that reject HEAD or omit the length.
+func (dp *DirectProvider) probe(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+
+	resp, err := dp.client.Do(req)
+	if err == nil && resp.StatusCode == http.StatusOK && resp.ContentLength > 0 {
+		return resp, nil
+	}
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	req, err = http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err = dp.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("probe request failed: %w", err)
+	}
+	return resp, nil
+}
+
+func directSize(resp *http.Response) int64 {
+	// "bytes 0-0/12345"
+	if cr := resp.Header.Get("Content-Range"); cr != "" {
+		if idx := strings.LastIndex(cr, "/"); idx != -1 {
+			if n, err := strconv.ParseInt(cr[idx+1:], 10, 64); err == nil {
+				return n
+			}
+		}
+		return 0
+	}
+	if resp.ContentLength > 0 {
+		return resp.ContentLength
+	}
+	return 0
+}
+
+func directFileName(disposition string, u *url.URL) string {
+	if _, params, err := mime.ParseMediaType(disposition); err == nil {
+		if name := path.Base(strings.ReplaceAll(params["filename"], `\`, "/")); name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+	if name := path.Base(u.Path); name != "" && name != "." && name != "/" {
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			return unescaped
+		}
+		return name
+	}
+	return "file"
+}
+
+func isDirectMime(mimeType string) bool {
+	if strings.HasPrefix(mimeType, "video/") || strings.HasPrefix(mimeType, "audio/") || strings.HasPrefix(mimeType, "image/") {
+		return true
+	}
+	for _, known := range directTypes {
+		if known == mimeType {
+			return true
+		}
+	}
+	return false
+}
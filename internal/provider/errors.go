@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+)
+
+var (
+	ErrUnsupported        = errs.New(errs.Unsupported, "this link is not supported")
+	ErrContentPrivate     = errs.New(errs.Private, "content is private")
+	ErrAgeRestricted      = errs.New(errs.Private, "content is age restricted")
+	ErrGeoBlocked         = errs.New(errs.Private, "content is not available in this region")
+	ErrContentUnavailable = errs.New(errs.NotFound, "content is unavailable or was removed")
+	ErrLiveStream         = errs.New(errs.Unsupported, "live streams can only be recorded with /record")
+	ErrNotLive            = errs.New(errs.Unsupported, "this link is not a live stream")
+	ErrTooLong            = errs.New(errs.TooLarge, "content is too long")
+	ErrTooLarge           = errs.New(errs.TooLarge, "file is larger than the upload limit")
+	ErrLoginRequired      = errs.New(errs.Private, "login is required to access this content")
+	ErrRateLimited        = errs.New(errs.RateLimited, "too many requests, try again later")
+	ErrUpstreamDown       = errs.New(errs.UpstreamDown, "service is temporarily unavailable")
+	ErrUpstreamFailed     = errs.New(errs.Internal, "service could not process this link")
+)
+
+// CobaltError is returned when Cobalt answers with status "error". It
+// unwraps to one of the Err* sentinels so callers can use errors.Is.
+type CobaltError struct {
+	Code    string
+	Context string
+	kind    error
+}
+
+func (e *CobaltError) Error() string {
+	return e.kind.Error()
+}
+
+func (e *CobaltError) Unwrap() error {
+	return e.kind
+}
+
+func newCobaltError(code, context string) *CobaltError {
+	return &CobaltError{Code: code, Context: context, kind: classifyCobaltCode(code)}
+}
+
+// cobaltErrorKinds maps fragments of Cobalt error codes to error kinds.
+// Order matters: the first matching fragment wins.
+var cobaltErrorKinds = []struct {
+	fragment string
+	kind     error
+}{
+	{".private", ErrContentPrivate},
+	{".age", ErrAgeRestricted},
+	{".region", ErrGeoBlocked},
+	{"content.video.live", ErrLiveStream},
+	{"content.too_long", ErrTooLong},
+	{".unavailable", ErrContentUnavailable},
+	{"fetch.empty", ErrContentUnavailable},
+	{"rate_exceeded", ErrRateLimited},
+	{"fetch.rate", ErrRateLimited},
+	{"youtube.login", ErrLoginRequired},
+	{"service.unsupported", ErrUnsupported},
+	{"service.disabled", ErrUnsupported},
+	{"link.invalid", ErrUnsupported},
+	{"link.unsupported", ErrUnsupported},
+	{"api.unreachable", ErrUpstreamDown},
+	{"api.timed_out", ErrUpstreamDown},
+	{"api.capacity", ErrUpstreamDown},
+	{"api.auth.", ErrUpstreamDown},
+	{"fetch.fail", ErrUpstreamDown},
+	{"fetch.critical", ErrUpstreamDown},
+}
+
+func classifyCobaltCode(code string) error {
+	for _, k := range cobaltErrorKinds {
+		if strings.Contains(code, k.fragment) {
+			return k.kind
+		}
+	}
+	return ErrUpstreamFailed
+}
+
+// tikwmErrorKinds maps fragments of tikwm's "msg" field to error kinds.
+// Most failures are bad, private or removed links, not API faults.
+var tikwmErrorKinds = []struct {
+	fragment string
+	kind     error
+}{
+	{"limit", ErrRateLimited},
+	{"private", ErrContentPrivate},
+	{"url parsing", ErrUnsupported},
+	{"check url", ErrUnsupported},
+	{"not available", ErrContentUnavailable},
+	{"not exist", ErrContentUnavailable},
+	{"not found", ErrContentUnavailable},
+	{"deleted", ErrContentUnavailable},
+	{"removed", ErrContentUnavailable},
+	{"no data", ErrContentUnavailable},
+}
+
+func classifyTikwmMessage(msg string) error {
+	lower := strings.ToLower(msg)
+	for _, k := range tikwmErrorKinds {
+		if strings.Contains(lower, k.fragment) {
+			return k.kind
+		}
+	}
+	return ErrUpstreamFailed
+}
+
+// galleryErrorKinds maps fragments of gallery-dl's error names and stderr
+// to error kinds.
+var galleryErrorKinds = []struct {
+	fragment string
+	kind     error
+}{
+	{"authenticationerror", ErrLoginRequired},
+	{"authorizationerror", ErrContentPrivate},
+	{"notfounderror", ErrContentUnavailable},
+	{"404 not found", ErrContentUnavailable},
+	{"429 too many requests", ErrRateLimited},
+	{"no suitable extractor", ErrUnsupported},
+	{"unsupported url", ErrUnsupported},
+}
+
+func classifyGalleryError(text string) error {
+	lower := strings.ToLower(text)
+	for _, k := range galleryErrorKinds {
+		if strings.Contains(lower, k.fragment) {
+			return k.kind
+		}
+	}
+	return ErrUpstreamFailed
+}
+
+// YtdlpError is a yt-dlp failure classified from its stderr. It unwraps to
+// one of the Err* sentinels and records the cookie file that was used.
+type YtdlpError struct {
+	Stderr     string
+	CookieFile string
+	kind       error
+}
+
+func (e *YtdlpError) Error() string {
+	return e.kind.Error()
+}
+
+func (e *YtdlpError) Unwrap() error {
+	return e.kind
+}
+
+// ytdlpErrorKinds maps fragments of yt-dlp stderr to error kinds. Order
+// matters: the bot check and age wall also say "Sign in to confirm". The
+// bot check is YouTube throttling the server's IP, not a cookie problem.
+var ytdlpErrorKinds = []struct {
+	fragment string
+	kind     error
+}{
+	{"not a bot", ErrRateLimited},
+	{"confirm your age", ErrAgeRestricted},
+	{"age-restricted", ErrAgeRestricted},
+	{"inappropriate for some users", ErrAgeRestricted},
+	{"private video", ErrContentPrivate},
+	{"video is private", ErrContentPrivate},
+	{"not available in your country", ErrGeoBlocked},
+	{"blocked it in your country", ErrGeoBlocked},
+	{"geo restriction", ErrGeoBlocked},
+	{"geo-restricted", ErrGeoBlocked},
+	{"http error 429", ErrRateLimited},
+	{"too many requests", ErrRateLimited},
+	{"rate-limited", ErrRateLimited},
+	{"members-only", ErrLoginRequired},
+	{"join this channel", ErrLoginRequired},
+	{"cookies are no longer valid", ErrLoginRequired},
+	{"login required", ErrLoginRequired},
+	{"sign in", ErrLoginRequired},
+	{"has been removed", ErrContentUnavailable},
+	{"has been terminated", ErrContentUnavailable},
+	{"video unavailable", ErrContentUnavailable},
+	{"does not exist", ErrContentUnavailable},
+	{"http error 404", ErrContentUnavailable},
+	{"live event will begin", ErrLiveStream},
+}
+
+// classifyYtdlpStderr returns a *YtdlpError for recognised failures, or nil
+// if stderr does not match a known kind.
+func classifyYtdlpStderr(stderr, cookieFile string) *YtdlpError {
+	lower := strings.ToLower(stderr)
+	for _, k := range ytdlpErrorKinds {
+		if strings.Contains(lower, k.fragment) {
+			return &YtdlpError{Stderr: stderr, CookieFile: cookieFile, kind: k.kind}
+		}
+	}
+	return nil
+}
+
+// IsAuthError reports whether err means the site wants a (working) login:
+// a login wall, expired cookies, or an age wall even though cookies were
+// sent. Without cookies an age wall is just restricted content.
+func IsAuthError(err error) bool {
+	if errors.Is(err, ErrLoginRequired) {
+		return true
+	}
+	var ytErr *YtdlpError
+	return errors.Is(err, ErrAgeRestricted) && errors.As(err, &ytErr) && ytErr.CookieFile != ""
+}
@@ -0,0 +1,82 @@
+package provider
+
+import (
+	"fmt"
+	"sort"
+)
+
+// QualityFormat returns the yt-dlp format selector for the best video up to
+// height, merged with the best audio.
+func QualityFormat(height int) string {
+	return fmt.Sprintf("bestvideo[height<=%d]+bestaudio/best[height<=%d]", height, height)
+}
+
+// WithQuality makes a piped item download the best video up to height and
+// updates its size estimate from the listed formats.
+func WithQuality(info VideoInfo, height int) VideoInfo {
+	info.Format = QualityFormat(height)
+	if f := formatForHeight(info.Formats, height); f != nil && f.Size > 0 {
+		info.FileSize = f.Size
+	}
+	return info
+}
+
+// youtubeFormats lists one entry per video height, largest first, followed
+// by the best audio-only format. Sizes include the audio track.
+func youtubeFormats(meta ytdlpMeta) []Format {
+	var audio *Format
+	byHeight := make(map[int]Format)
+	for _, f := range meta.Formats {
+		size := ytdlpFormatSize(f, meta.Duration)
+		if f.VCodec == "none" || f.VCodec == "" {
+			if f.ACodec == "none" || f.ACodec == "" {
+				continue
+			}
+			if audio == nil || size > audio.Size {
+				audio = &Format{Label: "audio", Ext: f.Ext, Size: size}
+			}
+			continue
+		}
+		if f.Height <= 0 {
+			continue
+		}
+		if cur, ok := byHeight[f.Height]; !ok || size > cur.Size {
+			byHeight[f.Height] = Format{Label: fmt.Sprintf("%dp", f.Height), Ext: f.Ext, Height: f.Height, Size: size}
+		}
+	}
+
+	formats := make([]Format, 0, len(byHeight)+1)
+	for _, f := range byHeight {
+		if audio != nil && f.Size > 0 {
+			f.Size += audio.Size
+		}
+		formats = append(formats, f)
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i].Height > formats[j].Height })
+	if audio != nil {
+		formats = append(formats, *audio)
+	}
+	return formats
+}
+
+func ytdlpFormatSize(f ytdlpFormat, duration float64) int64 {
+	switch {
+	case f.FileSize > 0:
+		return f.FileSize
+	case f.FileSizeApp > 0:
+		return f.FileSizeApp
+	case f.TBR > 0 && duration > 0:
+		return int64(f.TBR * 1000 * duration / 8)
+	}
+	return 0
+}
+
+// formatForHeight returns the tallest format not taller than height.
+func formatForHeight(formats []Format, height int) *Format {
+	for i := range formats {
+		if formats[i].Height > 0 && formats[i].Height <= height {
+			return &formats[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/internal/cookies"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const (
+	galleryDLTimeout   = 3 * time.Minute
+	galleryDLUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:128.0) Gecko/20100101 Firefox/128.0"
+
+	// gallery-dl --dump-json message types
+	galleryMsgURL = 3
+)
+
+var galleryDLDomains = []string{
+	"pixiv.net",
+	"deviantart.com",
+	"danbooru.donmai.us",
+	"imgur.com",
+	"tumblr.com",
+	"artstation.com",
+	"gelbooru.com",
+}
+
+type GalleryDLProvider struct {
+}
+
+func NewGalleryDL() *GalleryDLProvider {
+	return &GalleryDLProvider{}
+}
+
+func (gp *GalleryDLProvider) Name() string {
+	return "gallery-dl"
+}
+
+func (gp *GalleryDLProvider) Supports(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range galleryDLDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (gp *GalleryDLProvider) GetVideoInfo(ctx context.Context, rawURL string, opts Options) ([]VideoInfo, error) {
+	if opts.AudioOnly {
+		return nil, ErrUnsupported
+	}
+
+	limit := config.GetGalleryMaxItems()
+	args := []string{
+		"--dump-json",
+		"--range", fmt.Sprintf("1-%d", limit),
+	}
+
+	jar := cookies.ForURL(rawURL)
+	if jar != nil {
+		logger.Info("Using gallery-dl cookies", "path", jar.Path)
+		args = append(args, "--cookies", jar.Path)
+	}
+	args = append(args, rawURL)
+
+	ctx, cancel := context.WithTimeout(ctx, galleryDLTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "gallery-dl", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil && stdout.Len() == 0 {
+		logger.Warn("gallery-dl failed", "url", rawURL, "error", err, "stderr", stderr.String())
+		if ctx.Err() != nil {
+			return nil, errs.Wrap(errs.Timeout, "gallery-dl", ctx.Err())
+		}
+		return nil, fmt.Errorf("gallery-dl failed: %w", classifyGalleryError(stderr.String()))
+	}
+
+	var messages []json.RawMessage
+	if err := json.Unmarshal(stdout.Bytes(), &messages); err != nil {
+		return nil, fmt.Errorf("decode json failed: %w", err)
+	}
+
+	var results []VideoInfo
+	for _, raw := range messages {
+		var msg []json.RawMessage
+		if err := json.Unmarshal(raw, &msg); err != nil || len(msg) < 2 {
+			continue
+		}
+
+		var kind int
+		if err := json.Unmarshal(msg[0], &kind); err != nil {
+			continue
+		}
+		if kind < 0 {
+			var failure struct {
+				Error   string `json:"error"`
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(msg[1], &failure)
+			if failure.Error == "AuthenticationError" || failure.Error == "AuthorizationError" {
+				cookies.MarkBad(jar, "login wall")
+			}
+			if len(results) == 0 {
+				return nil, fmt.Errorf("gallery-dl %s: %s: %w", failure.Error, failure.Message, classifyGalleryError(failure.Error+" "+failure.Message))
+			}
+			continue
+		}
+		if kind != galleryMsgURL || len(msg) < 3 {
+			continue
+		}
+
+		var fileURL string
+		if err := json.Unmarshal(msg[1], &fileURL); err != nil || !strings.HasPrefix(fileURL, "http") {
+			// ytdl: and text: URLs are not direct files
+			continue
+		}
+		var meta galleryMeta
+		if err := json.Unmarshal(msg[2], &meta); err != nil {
+			continue
+		}
+
+		results = append(results, meta.toVideoInfo(fileURL, rawURL, len(results)))
+		if len(results) >= limit {
+			break
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no media found")
+	}
+
+	logger.Info("gallery-dl resolved", "url", rawURL, "items", len(results))
+	return results, nil
+}
+
+type galleryMeta struct {
+	Category    string            `json:"category"`
+	Filename    string            `json:"filename"`
+	Extension   string            `json:"extension"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	HTTPHeaders map[string]string `json:"_http_headers"`
+	User        json.RawMessage   `json:"user"`
+	Author      json.RawMessage   `json:"author"`
+	Artist      json.RawMessage   `json:"artist"`
+}
+
+func (m galleryMeta) toVideoInfo(fileURL, sourceURL string, index int) VideoInfo {
+	ext := strings.ToLower(m.Extension)
+	if ext == "" {
+		ext = strings.TrimPrefix(path.Ext(strings.SplitN(fileURL, "?", 2)[0]), ".")
+	}
+
+	// Most image hosts reject hotlinks without a referer from the site
+	headers := map[string]string{
+		"User-Agent": galleryDLUserAgent,
+		"Referer":    sourceURL,
+	}
+	for k, v := range m.HTTPHeaders {
+		headers[k] = v
+	}
+
+	name := m.Filename
+	if name == "" {
+		name = strconv.Itoa(index)
+	}
+
+	info := VideoInfo{
+		URL:      fileURL,
+		FileName: fmt.Sprintf("%s_%s.%s", m.Category, name, ext),
+		Title:    m.Title,
+		Caption:  m.Description,
+		Author:   firstNonEmpty(galleryName(m.User), galleryName(m.Author), galleryName(m.Artist)),
+		Width:    m.Width,
+		Height:   m.Height,
+		Headers:  headers,
+		MimeType: guessMimeType("." + ext),
+	}
+	if info.Title == "" {
+		info.Title = fmt.Sprintf("%s gallery", m.Category)
+	}
+
+	switch ext {
+	case "gif":
+		info.MimeType = "image/gif"
+	case "webp":
+		info.MimeType = "image/webp"
+	}
+	return info
+}
+
+// galleryName extracts a display name from gallery-dl's user/author/artist
+// fields, which are plain strings on some sites and objects on others.
+func galleryName(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var obj struct {
+		Name     string `json:"name"`
+		Username string `json:"username"`
+		Account  string `json:"account"`
+	}
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		return firstNonEmpty(obj.Name, obj.Username, obj.Account)
+	}
+	return ""
+}
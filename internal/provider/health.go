@@ -0,0 +1,312 @@
+package provider
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const (
+	circuitFailureThreshold = 5
+	circuitCooldown         = 60 * time.Second
+	healthProbeInterval     = 30 * time.Second
+	healthProbeTimeout      = 15 * time.Second
+	latencyWindow           = 100
+	recentErrorsLimit       = 5
+)
+
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Prober is implemented by providers that can cheaply check whether their
+// upstream is reachable. Open circuits of such providers are closed by the
+// background prober instead of by a live user request.
+type Prober interface {
+	Probe(ctx context.Context) error
+}
+
+// InstanceStats describes one upstream endpoint of a provider that balances
+// across several instances.
+type InstanceStats struct {
+	URL           string
+	Weight        int
+	Healthy       bool
+	Requests      int64
+	Failures      int64
+	LastError     string
+	LastErrorAt   time.Time
+	RateLimit     int
+	RateRemaining int
+	RateReset     time.Time
+}
+
+type instanceReporter interface {
+	InstanceStats() []InstanceStats
+}
+
+type ErrorRecord struct {
+	Time    time.Time
+	Message string
+}
+
+type HealthStats struct {
+	Name                string
+	State               CircuitState
+	Successes           int64
+	Failures            int64
+	ConsecutiveFailures int
+	P50                 time.Duration
+	P90                 time.Duration
+	P99                 time.Duration
+	OpenedAt            time.Time
+	RecentErrors        []ErrorRecord // Newest first
+	Instances           []InstanceStats
+}
+
+func (hs HealthStats) SuccessRate() float64 {
+	total := hs.Successes + hs.Failures
+	if total == 0 {
+		return 100
+	}
+	return float64(hs.Successes) / float64(total) * 100
+}
+
+func (hs HealthStats) LastError() *ErrorRecord {
+	if len(hs.RecentErrors) == 0 {
+		return nil
+	}
+	return &hs.RecentErrors[0]
+}
+
+type health struct {
+	mu          sync.Mutex
+	state       CircuitState
+	successes   int64
+	failures    int64
+	consecutive int
+	latencies   []time.Duration
+	latencyIdx  int
+	recent      []ErrorRecord
+	openedAt    time.Time
+	trial       bool
+}
+
+var (
+	healthMap = make(map[string]*health)
+	healthMu  sync.Mutex
+)
+
+func healthFor(name string) *health {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+
+	h, ok := healthMap[name]
+	if !ok {
+		h = &health{latencies: make([]time.Duration, 0, latencyWindow)}
+		healthMap[name] = h
+	}
+	return h
+}
+
+// allow reports whether a request may be sent to the provider. Providers
+// without a Prober get a single half-open trial request once the cooldown
+// has passed.
+func (h *health) allow(canProbe bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch h.state {
+	case CircuitClosed:
+		return true
+	case CircuitOpen:
+		if canProbe || time.Since(h.openedAt) < circuitCooldown {
+			return false
+		}
+		h.state = CircuitHalfOpen
+		h.trial = true
+		return true
+	default:
+		if h.trial {
+			return false
+		}
+		h.trial = true
+		return true
+	}
+}
+
+func (h *health) record(latency time.Duration, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.latencies) < latencyWindow {
+		h.latencies = append(h.latencies, latency)
+	} else {
+		h.latencies[h.latencyIdx] = latency
+	}
+	h.latencyIdx = (h.latencyIdx + 1) % latencyWindow
+
+	// A private or removed post is a correct answer from a healthy upstream
+	if err == nil || errs.KindOf(err).Content() {
+		h.successes++
+		h.close()
+		return
+	}
+
+	h.failures++
+	h.consecutive++
+	h.addError(err.Error())
+
+	if h.state == CircuitHalfOpen || h.consecutive >= circuitFailureThreshold {
+		h.open()
+	}
+}
+
+// abort releases a half-open trial whose request was cancelled before it
+// could tell us anything about the upstream.
+func (h *health) abort() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.trial = false
+}
+
+func (h *health) addError(msg string) {
+	h.recent = append([]ErrorRecord{{Time: time.Now(), Message: msg}}, h.recent...)
+	if len(h.recent) > recentErrorsLimit {
+		h.recent = h.recent[:recentErrorsLimit]
+	}
+}
+
+func (h *health) open() {
+	h.state = CircuitOpen
+	h.openedAt = time.Now()
+	h.trial = false
+}
+
+func (h *health) close() {
+	h.state = CircuitClosed
+	h.consecutive = 0
+	h.trial = false
+}
+
+func (h *health) snapshot(name string) HealthStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stats := HealthStats{
+		Name:                name,
+		State:               h.state,
+		Successes:           h.successes,
+		Failures:            h.failures,
+		ConsecutiveFailures: h.consecutive,
+		OpenedAt:            h.openedAt,
+		RecentErrors:        append([]ErrorRecord(nil), h.recent...),
+	}
+
+	if len(h.latencies) > 0 {
+		sorted := append([]time.Duration(nil), h.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		stats.P50 = percentile(sorted, 50)
+		stats.P90 = percentile(sorted, 90)
+		stats.P99 = percentile(sorted, 99)
+	}
+
+	return stats
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	idx := (len(sorted)*p+99)/100 - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// HealthSnapshot returns health stats for every registered provider in
+// registry order.
+func HealthSnapshot() []HealthStats {
+	mu.RLock()
+	providers := append([]Provider(nil), registry...)
+	mu.RUnlock()
+
+	result := make([]HealthStats, 0, len(providers))
+	for _, p := range providers {
+		stats := healthFor(p.Name()).snapshot(p.Name())
+		if reporter, ok := p.(instanceReporter); ok {
+			stats.Instances = reporter.InstanceStats()
+		}
+		result = append(result, stats)
+	}
+	return result
+}
+
+// StartHealthProbe periodically probes providers with an open circuit and
+// closes the circuit once the upstream answers again.
+func StartHealthProbe(ctx context.Context) {
+	ticker := time.NewTicker(healthProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			probeOpenCircuits(ctx)
+		}
+	}
+}
+
+func probeOpenCircuits(ctx context.Context) {
+	mu.RLock()
+	providers := append([]Provider(nil), registry...)
+	mu.RUnlock()
+
+	for _, p := range providers {
+		prober, ok := p.(Prober)
+		if !ok {
+			continue
+		}
+
+		h := healthFor(p.Name())
+		h.mu.Lock()
+		due := h.state == CircuitOpen && time.Since(h.openedAt) >= circuitCooldown
+		h.mu.Unlock()
+		if !due {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+		err := prober.Probe(probeCtx)
+		cancel()
+
+		h.mu.Lock()
+		if err == nil {
+			h.close()
+			logger.Info("Provider probe succeeded, circuit closed", "provider", p.Name())
+		} else {
+			h.addError("probe: " + err.Error())
+			h.open()
+			logger.Warn("Provider probe failed", "provider", p.Name(), "error", err)
+		}
+		h.mu.Unlock()
+	}
+}
@@ -0,0 +1,429 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/internal/cookies"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+)
+
+const (
+	instagramTimeout = 30 * time.Second
+	instagramAppID   = "936619743392459"
+	instagramDocID   = "8845758582119845" // PolarisPostActionLoadPostQueryQuery
+	instagramLSD     = "AVqbxe3J_YA"
+	instagramUA      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+)
+
+var (
+	instagramPostRegex      = regexp.MustCompile(`instagram\.com/(?:[\w.]+/)?(?:p|reels?|tv)/([\w-]+)`)
+	instagramStoryRegex     = regexp.MustCompile(`instagram\.com/stories/([\w.]+)/(\d+)`)
+	instagramHighlightRegex = regexp.MustCompile(`instagram\.com/stories/highlights/(\d+)`)
+	instagramContextRegex   = regexp.MustCompile(`"contextJSON":"((?:\\.|[^"\\])*)"`)
+)
+
+type InstagramProvider struct {
+	client *http.Client
+}
+
+func NewInstagram() *InstagramProvider {
+	return &InstagramProvider{
+		client: &http.Client{
+			Timeout: instagramTimeout,
+		},
+	}
+}
+
+func (ip *InstagramProvider) Name() string {
+	return "Instagram"
+}
+
+func (ip *InstagramProvider) Supports(url string) bool {
+	return strings.Contains(url, "instagram.com") || strings.Contains(url, "instagr.am")
+}
+
+func (ip *InstagramProvider) GetVideoInfo(ctx context.Context, rawURL string, opts Options) ([]VideoInfo, error) {
+	rawURL = strings.Replace(rawURL, "instagr.am/", "instagram.com/", 1)
+
+	if m := instagramHighlightRegex.FindStringSubmatch(rawURL); m != nil {
+		return ip.fetchPrivateItems(ctx, "https://i.instagram.com/api/v1/feed/reels_media/?reel_ids=highlight:"+m[1], opts)
+	}
+	if m := instagramStoryRegex.FindStringSubmatch(rawURL); m != nil {
+		return ip.fetchPrivateItems(ctx, "https://i.instagram.com/api/v1/media/"+m[2]+"/info/", opts)
+	}
+
+	m := instagramPostRegex.FindStringSubmatch(rawURL)
+	if m == nil {
+		return nil, ErrUnsupported
+	}
+	shortcode := m[1]
+
+	media, err := ip.fetchGraphQL(ctx, shortcode)
+	if err != nil || media == nil {
+		media, err = ip.fetchEmbed(ctx, shortcode)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return media.toVideoInfos(shortcode, opts)
+}
+
+// fetchGraphQL loads a public post through the same GraphQL query the web
+// client uses for logged-out visitors.
+func (ip *InstagramProvider) fetchGraphQL(ctx context.Context, shortcode string) (*igMedia, error) {
+	variables, _ := json.Marshal(map[string]string{"shortcode": shortcode})
+	form := url.Values{}
+	form.Set("av", "0")
+	form.Set("__d", "www")
+	form.Set("__user", "0")
+	form.Set("__a", "1")
+	form.Set("lsd", instagramLSD)
+	form.Set("fb_api_req_friendly_name", "PolarisPostActionLoadPostQueryQuery")
+	form.Set("variables", string(variables))
+	form.Set("doc_id", instagramDocID)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://www.instagram.com/graphql/query", bytes.NewBufferString(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", instagramUA)
+	req.Header.Set("X-IG-App-ID", instagramAppID)
+	req.Header.Set("X-FB-LSD", instagramLSD)
+	req.Header.Set("X-ASBD-ID", "129477")
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+
+	var result struct {
+		Data struct {
+			Media *igMedia `json:"xdt_shortcode_media"`
+		} `json:"data"`
+	}
+	if err := ip.doJSON(req, &result); err != nil {
+		return nil, err
+	}
+	return result.Data.Media, nil
+}
+
+// fetchEmbed falls back to the JSON embedded in the public embed page.
+func (ip *InstagramProvider) fetchEmbed(ctx context.Context, shortcode string) (*igMedia, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://www.instagram.com/p/"+shortcode+"/embed/captioned/", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("User-Agent", instagramUA)
+
+	resp, err := ip.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embed request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrContentUnavailable
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errs.Status("instagram embed", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read embed failed: %w", err)
+	}
+
+	m := instagramContextRegex.FindSubmatch(body)
+	if m == nil {
+		return nil, ErrContentPrivate
+	}
+	contextJSON, err := strconv.Unquote(`"` + string(m[1]) + `"`)
+	if err != nil {
+		return nil, fmt.Errorf("unquote embed context failed: %w", err)
+	}
+
+	var embed struct {
+		GQLData struct {
+			Media *igMedia `json:"shortcode_media"`
+		} `json:"gql_data"`
+	}
+	if err := json.Unmarshal([]byte(contextJSON), &embed); err != nil {
+		return nil, fmt.Errorf("decode embed context failed: %w", err)
+	}
+	if embed.GQLData.Media == nil {
+		return nil, ErrContentPrivate
+	}
+	return embed.GQLData.Media, nil
+}
+
+// fetchPrivateItems loads stories and highlights through the private API,
+// which needs a logged-in session from CookiesDir.
+func (ip *InstagramProvider) fetchPrivateItems(ctx context.Context, apiURL string, opts Options) ([]VideoInfo, error) {
+	jar := cookies.Pick("instagram.com")
+	if jar == nil {
+		return nil, ErrLoginRequired
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("User-Agent", instagramUA)
+	req.Header.Set("X-IG-App-ID", instagramAppID)
+	req.Header.Set("Cookie", jar.Header("instagram.com"))
+
+	var result struct {
+		Items []igAPIItem `json:"items"`
+		Reels map[string]struct {
+			Items []igAPIItem `json:"items"`
+		} `json:"reels"`
+		ReelsMedia []struct {
+			Items []igAPIItem `json:"items"`
+		} `json:"reels_media"`
+	}
+	if err := ip.doJSON(req, &result); err != nil {
+		if errors.Is(err, ErrLoginRequired) {
+			cookies.MarkBad(jar, "login wall")
+		}
+		return nil, err
+	}
+
+	items := result.Items
+	for _, reel := range result.Reels {
+		items = append(items, reel.Items...)
+	}
+	if len(result.Reels) == 0 {
+		for _, reel := range result.ReelsMedia {
+			items = append(items, reel.Items...)
+		}
+	}
+
+	var infos []VideoInfo
+	for _, item := range items {
+		infos = append(infos, item.toVideoInfos(opts)...)
+	}
+	if len(infos) == 0 {
+		return nil, ErrContentUnavailable
+	}
+	return infos, nil
+}
+
+func (ip *InstagramProvider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := ip.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("instagram request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrLoginRequired
+	case http.StatusNotFound:
+		return ErrContentUnavailable
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return errs.Status("instagram", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode failed: %w", err)
+	}
+	return nil
+}
+
+type igMedia struct {
+	Typename       string  `json:"__typename"`
+	DisplayURL     string  `json:"display_url"`
+	VideoURL       string  `json:"video_url"`
+	IsVideo        bool    `json:"is_video"`
+	Dimensions     igDims  `json:"dimensions"`
+	VideoDuration  float64 `json:"video_duration"`
+	VideoViewCount int64   `json:"video_view_count"`
+	Owner          struct {
+		Username string `json:"username"`
+		FullName string `json:"full_name"`
+	} `json:"owner"`
+	EdgeMediaToCaption struct {
+		Edges []struct {
+			Node struct {
+				Text string `json:"text"`
+			} `json:"node"`
+		} `json:"edges"`
+	} `json:"edge_media_to_caption"`
+	EdgeSidecarToChildren *struct {
+		Edges []struct {
+			Node igMedia `json:"node"`
+		} `json:"edges"`
+	} `json:"edge_sidecar_to_children"`
+	EdgeMediaPreviewLike struct {
+		Count int64 `json:"count"`
+	} `json:"edge_media_preview_like"`
+}
+
+type igDims struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (m *igMedia) toVideoInfos(shortcode string, opts Options) ([]VideoInfo, error) {
+	caption := ""
+	if len(m.EdgeMediaToCaption.Edges) > 0 {
+		caption = m.EdgeMediaToCaption.Edges[0].Node.Text
+	}
+
+	base := VideoInfo{
+		Title:   instagramTitle(caption, m.Owner.Username),
+		Caption: caption,
+		Author:  instagramAuthor(m.Owner.FullName, m.Owner.Username),
+		Views:   m.VideoViewCount,
+		Likes:   m.EdgeMediaPreviewLike.Count,
+	}
+
+	nodes := []igMedia{*m}
+	if m.EdgeSidecarToChildren != nil && len(m.EdgeSidecarToChildren.Edges) > 0 {
+		nodes = nodes[:0]
+		for _, edge := range m.EdgeSidecarToChildren.Edges {
+			nodes = append(nodes, edge.Node)
+		}
+	}
+
+	var results []VideoInfo
+	for i, node := range nodes {
+		info := base
+		info.Width = node.Dimensions.Width
+		info.Height = node.Dimensions.Height
+
+		if node.IsVideo {
+			if node.VideoURL == "" {
+				return nil, fmt.Errorf("video URL not available for %s", shortcode)
+			}
+			info.URL = node.VideoURL
+			info.Thumbnail = node.DisplayURL
+			info.Duration = int(node.VideoDuration)
+			info.FileName = fmt.Sprintf("instagram_%s_%d.mp4", shortcode, i)
+			info.MimeType = "video/mp4"
+			if opts.AudioOnly {
+				info.MimeType = "audio/mp4"
+			}
+		} else {
+			if opts.AudioOnly {
+				continue
+			}
+			info.URL = node.DisplayURL
+			info.FileName = fmt.Sprintf("instagram_%s_%d.jpg", shortcode, i)
+			info.MimeType = "image/jpeg"
+		}
+		results = append(results, info)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no media found in post %s", shortcode)
+	}
+	return results, nil
+}
+
+type igAPIItem struct {
+	ID             string  `json:"id"`
+	MediaType      int     `json:"media_type"` // 1 image, 2 video, 8 carousel
+	VideoDuration  float64 `json:"video_duration"`
+	ImageVersions2 struct {
+		Candidates []igCandidate `json:"candidates"`
+	} `json:"image_versions2"`
+	VideoVersions []igCandidate `json:"video_versions"`
+	CarouselMedia []igAPIItem   `json:"carousel_media"`
+	User          struct {
+		Username string `json:"username"`
+		FullName string `json:"full_name"`
+	} `json:"user"`
+	Caption *struct {
+		Text string `json:"text"`
+	} `json:"caption"`
+}
+
+type igCandidate struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+func (item igAPIItem) toVideoInfos(opts Options) []VideoInfo {
+	if len(item.CarouselMedia) > 0 {
+		var results []VideoInfo
+		for _, child := range item.CarouselMedia {
+			if child.Caption == nil {
+				child.Caption = item.Caption
+			}
+			child.User = item.User
+			results = append(results, child.toVideoInfos(opts)...)
+		}
+		return results
+	}
+
+	caption := ""
+	if item.Caption != nil {
+		caption = item.Caption.Text
+	}
+	info := VideoInfo{
+		Title:   instagramTitle(caption, item.User.Username),
+		Caption: caption,
+		Author:  instagramAuthor(item.User.FullName, item.User.Username),
+	}
+
+	if len(item.VideoVersions) > 0 {
+		best := item.VideoVersions[0]
+		info.URL = best.URL
+		info.Width, info.Height = best.Width, best.Height
+		info.Duration = int(item.VideoDuration)
+		info.FileName = fmt.Sprintf("instagram_%s.mp4", item.ID)
+		info.MimeType = "video/mp4"
+		if opts.AudioOnly {
+			info.MimeType = "audio/mp4"
+		}
+		if len(item.ImageVersions2.Candidates) > 0 {
+			info.Thumbnail = item.ImageVersions2.Candidates[0].URL
+		}
+		return []VideoInfo{info}
+	}
+
+	if opts.AudioOnly || len(item.ImageVersions2.Candidates) == 0 {
+		return nil
+	}
+	best := item.ImageVersions2.Candidates[0]
+	info.URL = best.URL
+	info.Width, info.Height = best.Width, best.Height
+	info.FileName = fmt.Sprintf("instagram_%s.jpg", item.ID)
+	info.MimeType = "image/jpeg"
+	return []VideoInfo{info}
+}
+
+func instagramTitle(caption, username string) string {
+	if line, _, _ := strings.Cut(strings.TrimSpace(caption), "\n"); line != "" {
+		return line
+	}
+	if username != "" {
+		return "Instagram post by @" + username
+	}
+	return "Instagram post"
+}
+
+func instagramAuthor(fullName, username string) string {
+	switch {
+	case fullName != "" && username != "":
+		return fmt.Sprintf("%s (@%s)", fullName, username)
+	case username != "":
+		return "@" + username
+	default:
+		return fullName
+	}
+}
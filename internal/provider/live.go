@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/internal/cookies"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const liveProbeTimeout = time.Minute
+
+// ResolveLive asks yt-dlp for the HLS playlist of a live stream on any site
+// it supports (YouTube, Twitch, ...). The returned item records the stream
+// with ffmpeg for at most duration; close stop to end it early.
+func ResolveLive(ctx context.Context, url string, duration time.Duration, stop <-chan struct{}) (VideoInfo, error) {
+	args := []string{
+		"--dump-json",
+		"--no-playlist",
+		"--no-warnings",
+		"-f", "best[protocol^=m3u8]/best",
+	}
+	jar := cookies.ForURL(url)
+	if jar != nil {
+		args = append(args, "--cookies", jar.Path)
+	}
+	args = append(args, url)
+
+	ctx, cancel := context.WithTimeout(ctx, liveProbeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		cookieFile := ""
+		if jar != nil {
+			cookieFile = jar.Path
+		}
+		if ytErr := classifyYtdlpStderr(stderr.String(), cookieFile); ytErr != nil {
+			if ytErr.kind == ErrLiveStream {
+				// An upcoming premiere or scheduled stream
+				return VideoInfo{}, ErrNotLive
+			}
+			if IsAuthError(ytErr) {
+				cookies.MarkBad(jar, ytErr.Error())
+			}
+			return VideoInfo{}, ytErr
+		}
+		if strings.Contains(stderr.String(), "Unsupported URL") {
+			return VideoInfo{}, ErrUnsupported
+		}
+		logger.Warn("yt-dlp live probe failed", "url", url, "error", err, "stderr", stderr.String())
+		if ctx.Err() != nil {
+			return VideoInfo{}, errs.Wrap(errs.Timeout, "yt-dlp live probe", ctx.Err())
+		}
+		return VideoInfo{}, fmt.Errorf("yt-dlp live probe failed: %w", ErrUpstreamFailed)
+	}
+
+	var meta ytdlpMeta
+	if err := json.Unmarshal(stdout.Bytes(), &meta); err != nil {
+		return VideoInfo{}, fmt.Errorf("decode json failed: %w", err)
+	}
+	if !meta.IsLive && meta.LiveStatus != "is_live" {
+		return VideoInfo{}, ErrNotLive
+	}
+	if meta.URL == "" {
+		return VideoInfo{}, fmt.Errorf("no stream URL for live stream")
+	}
+
+	title := strings.TrimSpace(meta.Title)
+	name := []rune(strings.NewReplacer("/", "_", "\\", "_").Replace(title))
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	fileName := fmt.Sprintf("%s_%s.mp4", string(name), time.Now().Format("20060102-1504"))
+
+	logger.Info("Live stream resolved", "title", title, "res", fmt.Sprintf("%dx%d", meta.Width, meta.Height), "duration", duration)
+
+	info := VideoInfo{
+		URL:      url,
+		FileName: fileName,
+		Title:    title,
+		Author:   meta.Uploader,
+		MimeType: "video/mp4",
+		Width:    meta.Width,
+		Height:   meta.Height,
+		Headers:  meta.HttpHeaders,
+		Mux: &MuxSpec{
+			Inputs:      []string{meta.URL},
+			Mode:        "copy",
+			MaxDuration: int(duration.Seconds()),
+			Stop:        stop,
+		},
+	}
+	if jar != nil {
+		info.CookieFile = jar.Path
+	}
+	return info, nil
+}
@@ -0,0 +1,232 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	pkghttp "github.com/pavelc4/aether-tg-bot/pkg/http"
+)
+
+const mastodonTimeout = 30 * time.Second
+
+var (
+	// https://instance/@user/123, https://instance/@user@remote/123 and
+	// https://instance/users/user/statuses/123
+	mastodonStatusRegex = regexp.MustCompile(`^https?://([^/]+)/(?:@[\w.-]+(?:@[\w.-]+)?|users/[\w.-]+/statuses)/(\d+)(?:[/?#]|$)`)
+	mastodonBreakRegex  = regexp.MustCompile(`(?i)<br\s*/?>|</p>\s*<p[^>]*>`)
+	mastodonTagRegex    = regexp.MustCompile(`<[^>]+>`)
+)
+
+// mastodonInstances are large instances whose links are picked up without a
+// command. Status URLs on other hosts look like many other sites' URLs, so
+// they are only tried with /dl.
+var mastodonInstances = map[string]bool{
+	"mastodon.social":    true,
+	"mastodon.online":    true,
+	"mastodon.world":     true,
+	"mstdn.social":       true,
+	"mstdn.jp":           true,
+	"mas.to":             true,
+	"fosstodon.org":      true,
+	"hachyderm.io":       true,
+	"infosec.exchange":   true,
+	"techhub.social":     true,
+	"universeodon.com":   true,
+	"chaos.social":       true,
+	"troet.cafe":         true,
+	"mastodon.art":       true,
+	"masto.ai":           true,
+	"social.vivaldi.net": true,
+}
+
+type MastodonProvider struct {
+	client *http.Client
+}
+
+func NewMastodon() *MastodonProvider {
+	return &MastodonProvider{
+		// Instances are any host a user names with /dl
+		client: &http.Client{
+			Timeout:   mastodonTimeout,
+			Transport: &http.Transport{DialContext: pkghttp.PublicDialContext(mastodonTimeout)},
+		},
+	}
+}
+
+func (mp *MastodonProvider) Name() string {
+	return "Mastodon"
+}
+
+func (mp *MastodonProvider) Supports(url string) bool {
+	return mastodonStatusRegex.MatchString(url)
+}
+
+// Fallback reports whether url is on a host not known to run Mastodon.
+func (mp *MastodonProvider) Fallback(url string) bool {
+	m := mastodonStatusRegex.FindStringSubmatch(url)
+	return m == nil || !mastodonInstances[strings.ToLower(m[1])]
+}
+
+func (mp *MastodonProvider) GetVideoInfo(ctx context.Context, url string, opts Options) ([]VideoInfo, error) {
+	m := mastodonStatusRegex.FindStringSubmatch(url)
+	if m == nil {
+		return nil, ErrUnsupported
+	}
+	instance, id := m[1], m[2]
+
+	status, err := mp.fetchStatus(ctx, instance, id)
+	if err != nil {
+		return nil, err
+	}
+	if status.Reblog != nil {
+		status = status.Reblog
+	}
+
+	text := mastodonText(status.Content)
+	base := VideoInfo{
+		Title:   text,
+		Caption: text,
+		Author:  "@" + status.Account.Acct,
+		Likes:   status.FavouritesCount,
+	}
+	if status.Account.DisplayName != "" {
+		base.Author = fmt.Sprintf("%s (@%s)", status.Account.DisplayName, status.Account.Acct)
+	}
+	if text == "" {
+		base.Title = "Post by @" + status.Account.Acct
+	}
+
+	var results []VideoInfo
+	for i, media := range status.MediaAttachments {
+		mediaURL := media.URL
+		if mediaURL == "" {
+			mediaURL = media.RemoteURL
+		}
+		if mediaURL == "" {
+			continue
+		}
+
+		info := base
+		info.URL = mediaURL
+		info.PublicOnly = true
+		info.Thumbnail = media.PreviewURL
+		info.AltText = media.Description
+		info.Width = media.Meta.Original.Width
+		info.Height = media.Meta.Original.Height
+		info.Duration = int(media.Meta.Original.Duration)
+
+		ext := path.Ext(strings.SplitN(mediaURL, "?", 2)[0])
+		switch media.Type {
+		case "image":
+			if opts.AudioOnly {
+				continue
+			}
+			if ext == "" {
+				ext = ".jpg"
+			}
+			info.MimeType = guessMimeType(ext)
+			if !strings.HasPrefix(info.MimeType, "image/") {
+				info.MimeType = "image/jpeg"
+			}
+		case "video", "gifv":
+			if media.Type == "gifv" {
+				if opts.AudioOnly {
+					continue
+				}
+				info.Animated = true
+			}
+			ext = ".mp4"
+			info.MimeType = "video/mp4"
+			if opts.AudioOnly {
+				info.MimeType = "audio/mp4"
+			}
+		case "audio":
+			if ext == "" {
+				ext = ".mp3"
+			}
+			info.MimeType = guessMimeType(ext)
+		default:
+			continue
+		}
+
+		info.FileName = fmt.Sprintf("mastodon_%s_%d%s", id, i, ext)
+		results = append(results, info)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no media found in status %s", id)
+	}
+	return results, nil
+}
+
+func (mp *MastodonProvider) fetchStatus(ctx context.Context, instance, id string) (*mastodonStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("https://%s/api/v1/statuses/%s", instance, id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := mp.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("status request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrContentUnavailable
+	case http.StatusUnauthorized, http.StatusForbidden:
+		// Instances in authorized-fetch mode refuse anonymous API access
+		return nil, ErrLoginRequired
+	case http.StatusTooManyRequests:
+		return nil, ErrRateLimited
+	default:
+		return nil, errs.Status("mastodon instance", resp.StatusCode)
+	}
+
+	var status mastodonStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("decode failed: %w", err)
+	}
+	return &status, nil
+}
+
+// mastodonText converts status HTML into plain text.
+func mastodonText(content string) string {
+	text := mastodonBreakRegex.ReplaceAllString(content, "\n")
+	text = mastodonTagRegex.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+type mastodonStatus struct {
+	Content         string `json:"content"`
+	FavouritesCount int64  `json:"favourites_count"`
+	Account         struct {
+		Acct        string `json:"acct"`
+		DisplayName string `json:"display_name"`
+	} `json:"account"`
+	MediaAttachments []struct {
+		Type        string `json:"type"` // image, video, gifv, audio
+		URL         string `json:"url"`
+		RemoteURL   string `json:"remote_url"`
+		PreviewURL  string `json:"preview_url"`
+		Description string `json:"description"`
+		Meta        struct {
+			Original struct {
+				Width    int     `json:"width"`
+				Height   int     `json:"height"`
+				Duration float64 `json:"duration"`
+			} `json:"original"`
+		} `json:"meta"`
+	} `json:"media_attachments"`
+	Reblog *mastodonStatus `json:"reblog"`
+}
@@ -0,0 +1,485 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	pkghttp "github.com/pavelc4/aether-tg-bot/pkg/http"
+)
+
+const (
+	podcastTimeout   = 30 * time.Second
+	podcastMaxBody   = 20 * 1024 * 1024
+	podcastUserAgent = "Mozilla/5.0 (compatible; AetherBot/1.0; +https://github.com/pavelc4/aether-tg-bot)"
+	itunesLookupURL  = "https://itunes.apple.com/lookup"
+)
+
+var (
+	podcastFeedPathRegex = regexp.MustCompile(`(?i)(?:\.(?:rss|xml|atom)|/(?:rss|feed|atom|feed/podcast))/?(?:[?#]|$)`)
+	podcastHostRegex     = regexp.MustCompile(`(?i)^https?://(?:[\w-]+\.)*(?:feeds\.[\w.-]+|podcasts\.apple\.com|anchor\.fm|podcasters\.spotify\.com|buzzsprout\.com|transistor\.fm|simplecast\.com|libsyn\.com|podbean\.com|captivate\.fm|megaphone\.fm|art19\.com|omny\.fm|rss\.com)(?:[/:?#]|$)`)
+	podcastAppleRegex    = regexp.MustCompile(`podcasts\.apple\.com/.*?/id(\d+)(?:.*?[?&]i=(\d+))?`)
+	podcastLinkTagRegex  = regexp.MustCompile(`(?i)<link[^>]+type=["']application/(?:rss|atom)\+xml["'][^>]*>`)
+	podcastHrefRegex     = regexp.MustCompile(`(?i)href=["']([^"']+)["']`)
+)
+
+// PodcastFeed is a parsed RSS or Atom podcast feed.
+type PodcastFeed struct {
+	URL      string
+	Title    string
+	Author   string
+	Artwork  string
+	Episodes []PodcastEpisode // Newest first
+}
+
+type PodcastEpisode struct {
+	GUID      string
+	Title     string
+	Link      string
+	AudioURL  string
+	MimeType  string
+	Artwork   string
+	Size      int64
+	Duration  int
+	Published time.Time
+}
+
+type PodcastProvider struct {
+	client *http.Client
+}
+
+func NewPodcast() *PodcastProvider {
+	// Feed and page URLs come from users and feeds, so only public hosts
+	// are fetched
+	return &PodcastProvider{
+		client: &http.Client{
+			Timeout:   podcastTimeout,
+			Transport: &http.Transport{DialContext: pkghttp.PublicDialContext(podcastTimeout)},
+		},
+	}
+}
+
+func (pp *PodcastProvider) Name() string {
+	return "Podcast"
+}
+
+func (pp *PodcastProvider) Supports(url string) bool {
+	return podcastFeedPathRegex.MatchString(url) || podcastHostRegex.MatchString(url)
+}
+
+// Fallback reports whether url is only claimed for its feed-like path, which
+// blogs and sitemaps share, rather than for a known podcast host.
+func (pp *PodcastProvider) Fallback(url string) bool {
+	return !podcastHostRegex.MatchString(url)
+}
+
+// GetVideoInfo returns the episode selected with --episode (a 1-based
+// index or a GUID), the episode the page URL points to, or the latest one.
+func (pp *PodcastProvider) GetVideoInfo(ctx context.Context, url string, opts Options) ([]VideoInfo, error) {
+	feed, match, err := pp.Feed(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := match
+	if sel, ok := opts.Flags["episode"]; ok {
+		idx = feed.find(sel)
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: episode %q not found in feed", ErrContentUnavailable, sel)
+		}
+	}
+	if idx < 0 {
+		idx = 0
+	}
+
+	ep := feed.Episodes[idx]
+	artwork := ep.Artwork
+	if artwork == "" {
+		artwork = feed.Artwork
+	}
+
+	return []VideoInfo{{
+		URL:        ep.AudioURL,
+		FileName:   podcastFileName(ep),
+		Title:      ep.Title,
+		Author:     feed.Title,
+		Thumbnail:  artwork,
+		FileSize:   ep.Size,
+		MimeType:   ep.MimeType,
+		Duration:   ep.Duration,
+		Headers:    map[string]string{"User-Agent": podcastUserAgent},
+		PublicOnly: true,
+	}}, nil
+}
+
+// Feed loads the podcast behind url. It returns the index of the episode the
+// URL refers to, or -1 if it points at the show as a whole.
+func (pp *PodcastProvider) Feed(ctx context.Context, rawURL string) (*PodcastFeed, int, error) {
+	feedURL, episodeGUID := rawURL, ""
+
+	if m := podcastAppleRegex.FindStringSubmatch(rawURL); m != nil {
+		var err error
+		feedURL, episodeGUID, err = pp.appleLookup(ctx, m[1], m[2])
+		if err != nil {
+			return nil, -1, err
+		}
+	}
+
+	body, contentType, err := pp.get(ctx, feedURL)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	pageURL := ""
+	if !isFeedDocument(body, contentType) {
+		// An episode or show page: follow its <link rel="alternate"> feed
+		link, err := discoverFeed(body, feedURL)
+		if err != nil {
+			return nil, -1, err
+		}
+		pageURL, feedURL = feedURL, link
+		if body, _, err = pp.get(ctx, feedURL); err != nil {
+			return nil, -1, err
+		}
+	}
+
+	feed, err := parsePodcastFeed(body)
+	if err != nil {
+		return nil, -1, err
+	}
+	feed.URL = feedURL
+	if len(feed.Episodes) == 0 {
+		// A blog or news feed rather than a podcast
+		return nil, -1, fmt.Errorf("%w: feed has no audio episodes", ErrUnsupported)
+	}
+
+	match := -1
+	switch {
+	case episodeGUID != "":
+		match = feed.find(episodeGUID)
+	case pageURL != "":
+		for i, ep := range feed.Episodes {
+			if ep.Link != "" && sameURL(ep.Link, pageURL) {
+				match = i
+				break
+			}
+		}
+	}
+	return feed, match, nil
+}
+
+// find returns the episode whose GUID is sel, or else the sel-th newest
+// one. GUIDs win because the picker sends them and some feeds number their
+// episodes "1", "2", ...
+func (f *PodcastFeed) find(sel string) int {
+	for i, ep := range f.Episodes {
+		if ep.GUID == sel {
+			return i
+		}
+	}
+	if n, err := strconv.Atoi(sel); err == nil && n >= 1 && n <= len(f.Episodes) {
+		return n - 1
+	}
+	return -1
+}
+
+func (pp *PodcastProvider) appleLookup(ctx context.Context, showID, episodeID string) (string, string, error) {
+	q := url.Values{"id": {showID}, "entity": {"podcastEpisode"}, "limit": {"200"}}
+	body, _, err := pp.get(ctx, itunesLookupURL+"?"+q.Encode())
+	if err != nil {
+		return "", "", err
+	}
+
+	var result struct {
+		Results []struct {
+			Kind        string `json:"kind"`
+			WrapperType string `json:"wrapperType"`
+			TrackID     int64  `json:"trackId"`
+			FeedURL     string `json:"feedUrl"`
+			EpisodeGUID string `json:"episodeGuid"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", "", fmt.Errorf("decode lookup failed: %w", err)
+	}
+
+	feedURL, guid := "", ""
+	for _, r := range result.Results {
+		if r.FeedURL != "" && feedURL == "" {
+			feedURL = r.FeedURL
+		}
+		if episodeID != "" && strconv.FormatInt(r.TrackID, 10) == episodeID {
+			guid = r.EpisodeGUID
+		}
+	}
+	if feedURL == "" {
+		return "", "", ErrContentUnavailable
+	}
+	return feedURL, guid, nil
+}
+
+func (pp *PodcastProvider) get(ctx context.Context, rawURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("User-Agent", podcastUserAgent)
+
+	resp, err := pp.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, "", ErrContentUnavailable
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, "", ErrContentPrivate
+	default:
+		return nil, "", errs.Status("podcast feed", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, podcastMaxBody))
+	if err != nil {
+		return nil, "", fmt.Errorf("read body failed: %w", err)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+func isFeedDocument(body []byte, contentType string) bool {
+	if strings.Contains(contentType, "html") {
+		return false
+	}
+	head := strings.ToLower(string(body[:min(len(body), 1024)]))
+	return strings.Contains(head, "<rss") || strings.Contains(head, "<feed")
+}
+
+func discoverFeed(page []byte, pageURL string) (string, error) {
+	tag := podcastLinkTagRegex.Find(page)
+	if tag == nil {
+		return "", fmt.Errorf("%w: no podcast feed linked from page", ErrUnsupported)
+	}
+	m := podcastHrefRegex.FindSubmatch(tag)
+	if m == nil {
+		return "", fmt.Errorf("%w: no podcast feed linked from page", ErrUnsupported)
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(strings.ReplaceAll(string(m[1]), "&amp;", "&"))
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+func sameURL(a, b string) bool {
+	norm := func(s string) string {
+		s = strings.TrimPrefix(strings.TrimPrefix(s, "https://"), "http://")
+		s = strings.TrimPrefix(s, "www.")
+		if i := strings.IndexAny(s, "?#"); i != -1 {
+			s = s[:i]
+		}
+		return strings.TrimSuffix(s, "/")
+	}
+	return norm(a) == norm(b)
+}
+
+const itunesNS = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+type rssFeed struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Title       string `xml:"title"`
+		Author      string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		ImageURL    string `xml:"image>url"`
+		ITunesImage struct {
+			Href string `xml:"href,attr"`
+		} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		Items []struct {
+			Title     string `xml:"title"`
+			GUID      string `xml:"guid"`
+			Link      string `xml:"link"`
+			PubDate   string `xml:"pubDate"`
+			Enclosure struct {
+				URL    string `xml:"url,attr"`
+				Length string `xml:"length,attr"`
+				Type   string `xml:"type,attr"`
+			} `xml:"enclosure"`
+			Duration    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+			ITunesImage struct {
+				Href string `xml:"href,attr"`
+			} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string   `xml:"title"`
+	Author  string   `xml:"author>name"`
+	Logo    string   `xml:"logo"`
+	Icon    string   `xml:"icon"`
+	Entries []struct {
+		Title     string `xml:"title"`
+		ID        string `xml:"id"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Links     []struct {
+			Rel    string `xml:"rel,attr"`
+			Href   string `xml:"href,attr"`
+			Type   string `xml:"type,attr"`
+			Length string `xml:"length,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+func parsePodcastFeed(body []byte) (*PodcastFeed, error) {
+	var rss rssFeed
+	if err := xml.Unmarshal(body, &rss); err == nil {
+		ch := rss.Channel
+		feed := &PodcastFeed{Title: ch.Title, Author: ch.Author, Artwork: ch.ITunesImage.Href}
+		if feed.Artwork == "" {
+			feed.Artwork = ch.ImageURL
+		}
+		for _, item := range ch.Items {
+			if item.Enclosure.URL == "" || !isMediaEnclosure(item.Enclosure.Type) {
+				continue
+			}
+			size, _ := strconv.ParseInt(strings.TrimSpace(item.Enclosure.Length), 10, 64)
+			feed.Episodes = append(feed.Episodes, PodcastEpisode{
+				GUID:      firstNonEmpty(strings.TrimSpace(item.GUID), item.Enclosure.URL),
+				Title:     strings.TrimSpace(item.Title),
+				Link:      strings.TrimSpace(item.Link),
+				AudioURL:  item.Enclosure.URL,
+				MimeType:  enclosureMime(item.Enclosure.Type, item.Enclosure.URL),
+				Artwork:   item.ITunesImage.Href,
+				Size:      size,
+				Duration:  parseITunesDuration(item.Duration),
+				Published: parseFeedTime(item.PubDate),
+			})
+		}
+		sortEpisodes(feed.Episodes)
+		return feed, nil
+	}
+
+	var atom atomFeed
+	if err := xml.Unmarshal(body, &atom); err != nil {
+		return nil, fmt.Errorf("%w: not an RSS or Atom feed", ErrUnsupported)
+	}
+	feed := &PodcastFeed{Title: atom.Title, Author: atom.Author, Artwork: firstNonEmpty(atom.Logo, atom.Icon)}
+	for _, entry := range atom.Entries {
+		ep := PodcastEpisode{
+			GUID:      entry.ID,
+			Title:     strings.TrimSpace(entry.Title),
+			Published: parseFeedTime(firstNonEmpty(entry.Published, entry.Updated)),
+		}
+		for _, link := range entry.Links {
+			switch {
+			case link.Rel == "enclosure" && isMediaEnclosure(link.Type):
+				ep.AudioURL = link.Href
+				ep.MimeType = enclosureMime(link.Type, link.Href)
+				ep.Size, _ = strconv.ParseInt(link.Length, 10, 64)
+			case link.Rel == "" || link.Rel == "alternate":
+				ep.Link = link.Href
+			}
+		}
+		if ep.AudioURL == "" {
+			continue
+		}
+		if ep.GUID == "" {
+			ep.GUID = ep.AudioURL
+		}
+		feed.Episodes = append(feed.Episodes, ep)
+	}
+	sortEpisodes(feed.Episodes)
+	return feed, nil
+}
+
+func sortEpisodes(eps []PodcastEpisode) {
+	sort.SliceStable(eps, func(i, j int) bool {
+		return eps[i].Published.After(eps[j].Published)
+	})
+}
+
+func isMediaEnclosure(mimeType string) bool {
+	return mimeType == "" || strings.HasPrefix(mimeType, "audio/") || strings.HasPrefix(mimeType, "video/")
+}
+
+func enclosureMime(mimeType, rawURL string) string {
+	if mimeType != "" && mimeType != "audio/x-m4a" {
+		return mimeType
+	}
+	if guessed := guessMimeType(strings.SplitN(rawURL, "?", 2)[0]); guessed != "application/octet-stream" {
+		return guessed
+	}
+	return "audio/mpeg"
+}
+
+// parseITunesDuration accepts "SS", "MM:SS" and "HH:MM:SS".
+func parseITunesDuration(s string) int {
+	total := 0
+	for _, part := range strings.Split(strings.TrimSpace(s), ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + int(n)
+	}
+	return total
+}
+
+func parseFeedTime(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, time.RFC3339, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", "2 Jan 2006 15:04:05 -0700"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func podcastFileName(ep PodcastEpisode) string {
+	ext := path.Ext(strings.SplitN(ep.AudioURL, "?", 2)[0])
+	if ext == "" || len(ext) > 5 {
+		ext = ".mp3"
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, ep.Title)
+	if runes := []rune(name); len(runes) > 80 {
+		name = string(runes[:80])
+	}
+	if name == "" {
+		name = "episode"
+	}
+	return name + ext
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -2,24 +2,97 @@ package provider
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"time"
 )
 
 type VideoInfo struct {
-	URL      string            // Direct download URL
-	FileName string            // Suggested filename
-	Title    string            // Title of the media
-	Caption  string            // Description/Caption of the media
-	FileSize int64             // File size in bytes (0 if unknown)
-	MimeType string            // MIME type (video/mp4, etc.)
-	Duration int               // Duration in seconds
-	Width    int               // Video width
-	Height   int               // Video height
-	Headers  map[string]string // Required headers for the request (cookies, referer, etc.)
-	UsePipe  bool              // If true, use yt-dlp pipe instead of direct download
+	URL          string            // Direct download URL
+	FileName     string            // Suggested filename
+	Title        string            // Title of the media
+	Caption      string            // Description/Caption of the media
+	AltText      string            // Accessibility description of this item
+	Author       string            // Uploader/author display name
+	Thumbnail    string            // Cover/thumbnail URL
+	Views        int64             // Play/view count (0 if unknown)
+	Likes        int64             // Like count (0 if unknown)
+	FileSize     int64             // File size in bytes (0 if unknown)
+	MimeType     string            // MIME type (video/mp4, etc.)
+	Duration     int               // Duration in seconds
+	Width        int               // Video width
+	Height       int               // Video height
+	Headers      map[string]string // Required headers for the request (cookies, referer, etc.)
+	CookieFile   string            // Cookie file used to resolve, reused by yt-dlp when downloading
+	Clip         *Clip             // Time range passed to yt-dlp --download-sections
+	Subtitle     *Subtitle         // Subtitle track requested with --subs, sent after the media
+	SubLangs     []string          // Languages with uploaded subtitles
+	Formats      []Format          // Formats offered by the site, shown by /info
+	Format       string            // yt-dlp format selector for the pipe, overrides the default
+	CaptionLangs []string          // Languages with automatic captions
+	UsePipe      bool              // If true, use yt-dlp pipe instead of direct download
+	Animated     bool              // Silent looping video (GIF), sent as an animation
+	AsDocument   bool              // Send as a plain file instead of a photo or playable media
+	PublicOnly   bool              // The host came from the user; only download from public addresses
+	Mux          *MuxSpec          // If set, merge the inputs with ffmpeg instead of direct download
+	PipeCommand  []string          // If set, stream the stdout of this command instead of URL
+}
+
+// MuxSpec describes streams that have to be merged locally into a single
+// output, e.g. separate video and audio tunnels.
+type MuxSpec struct {
+	Inputs       []string          // Input URLs in ffmpeg input order
+	Mode         string            // merge, mute, audio, gif, remux, copy or srt
+	AudioFormat  string            // Target audio codec for "audio" mode (mp3, opus, ogg, wav)
+	AudioBitrate string            // Target audio bitrate in kbps
+	AudioCopy    bool              // Copy the audio stream without re-encoding
+	Subtitles    bool              // The last input is a subtitle track
+	Metadata     map[string]string // Container metadata (title, artist, ...)
+	Start        int               // Seek every input to this many seconds before reading
+	MaxDuration  int               // Stop after this many seconds, 0 for no limit
+	Stop         <-chan struct{}   // Closing it ends ffmpeg cleanly, keeping what was written
+}
+
+// Format is one quality a site offers for an item.
+type Format struct {
+	Label  string // "1080p", "720p" or "audio"
+	Ext    string
+	Height int
+	Size   int64 // Estimated size in bytes, 0 if unknown
 }
 
 type Options struct {
 	AudioOnly bool
+	Flags     map[string]string // Per-request overrides from command flags (--key value)
+	Clip      *Clip             // Only download this time range
+	Explicit  bool              // Requested with a command such as /dl rather than a bare link
+}
+
+// Clip is a time range within a video or audio track.
+type Clip struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func (c Clip) Length() time.Duration {
+	return c.End - c.Start
+}
+
+// CacheKey identifies the result of downloading url with these options.
+func (o Options) CacheKey(url string) string {
+	key := fmt.Sprintf("%s|%t", url, o.AudioOnly)
+	names := make([]string, 0, len(o.Flags))
+	for name := range o.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key += "|" + name + "=" + o.Flags[name]
+	}
+	if o.Clip != nil {
+		key += fmt.Sprintf("|clip=%d-%d", int(o.Clip.Start.Seconds()), int(o.Clip.End.Seconds()))
+	}
+	return key
 }
 
 type Provider interface {
@@ -0,0 +1,45 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces out requests to an upstream so that at most one
+// request starts per interval.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{interval: interval}
+}
+
+// Wait blocks until the caller may send its request or ctx is done.
+func (rl *rateLimiter) Wait(ctx context.Context) error {
+	rl.mu.Lock()
+	now := time.Now()
+	start := rl.next
+	if start.Before(now) {
+		start = now
+	}
+	rl.next = start.Add(rl.interval)
+	rl.mu.Unlock()
+
+	delay := time.Until(start)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
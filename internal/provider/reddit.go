@@ -0,0 +1,387 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+)
+
+const (
+	redditTimeout   = 30 * time.Second
+	redditUserAgent = "linux:aether-tg-bot:v1.0 (by /u/aether-bot)"
+)
+
+var (
+	redditHostRegex    = regexp.MustCompile(`(?:^|[/.])(?:reddit\.com|redd\.it)/`)
+	redditPostRegex    = regexp.MustCompile(`reddit\.com/(?:r/[\w]+/)?(?:comments|gallery)/(\w+)`)
+	redditShortRegex   = regexp.MustCompile(`//redd\.it/(\w+)`)
+	redditImageRegex   = regexp.MustCompile(`//i\.redd\.it/[\w.-]+`)
+	redditRedirectHost = regexp.MustCompile(`//v\.redd\.it/|reddit\.com/r/\w+/s/`)
+)
+
+type RedditProvider struct {
+	client *http.Client
+}
+
+func NewReddit() *RedditProvider {
+	return &RedditProvider{
+		client: &http.Client{
+			Timeout: redditTimeout,
+		},
+	}
+}
+
+func (rp *RedditProvider) Name() string {
+	return "Reddit"
+}
+
+func (rp *RedditProvider) Supports(url string) bool {
+	return redditHostRegex.MatchString(url)
+}
+
+func (rp *RedditProvider) GetVideoInfo(ctx context.Context, rawURL string, opts Options) ([]VideoInfo, error) {
+	if redditImageRegex.MatchString(rawURL) {
+		if opts.AudioOnly {
+			return nil, ErrUnsupported
+		}
+		return []VideoInfo{{
+			URL:      rawURL,
+			FileName: path.Base(strings.SplitN(rawURL, "?", 2)[0]),
+			Title:    "Reddit image",
+			MimeType: guessMimeType(rawURL),
+			Headers:  map[string]string{"User-Agent": redditUserAgent},
+		}}, nil
+	}
+
+	// Share links (/s/...) and v.redd.it links redirect to the post
+	if redditRedirectHost.MatchString(rawURL) {
+		resolved, err := rp.followRedirect(ctx, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		rawURL = resolved
+	}
+
+	var postID string
+	if m := redditPostRegex.FindStringSubmatch(rawURL); m != nil {
+		postID = m[1]
+	} else if m := redditShortRegex.FindStringSubmatch(rawURL); m != nil {
+		postID = m[1]
+	} else {
+		return nil, ErrUnsupported
+	}
+
+	post, err := rp.fetchPost(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	base := VideoInfo{
+		Title:   html.UnescapeString(post.Title),
+		Caption: post.Selftext,
+		Author:  fmt.Sprintf("u/%s in r/%s", post.Author, post.Subreddit),
+		Likes:   post.Ups,
+		Headers: map[string]string{"User-Agent": redditUserAgent},
+	}
+
+	// Crossposts carry their media on the original post
+	if len(post.CrosspostParentList) > 0 {
+		post = &post.CrosspostParentList[0]
+	}
+
+	switch {
+	case post.IsGallery:
+		return rp.galleryInfos(post, base, postID, opts)
+	case post.SecureMedia.RedditVideo != nil:
+		return rp.videoInfos(ctx, post.SecureMedia.RedditVideo, base, postID, opts)
+	case post.Preview.RedditVideoPreview != nil && !post.IsSelf:
+		return rp.videoInfos(ctx, post.Preview.RedditVideoPreview, base, postID, opts)
+	case post.PostHint == "image" && !opts.AudioOnly:
+		info := base
+		info.URL = html.UnescapeString(post.URL)
+		info.FileName = fmt.Sprintf("reddit_%s%s", postID, path.Ext(strings.SplitN(post.URL, "?", 2)[0]))
+		info.MimeType = guessMimeType(info.FileName)
+		if len(post.Preview.Images) > 0 {
+			info.Width = post.Preview.Images[0].Source.Width
+			info.Height = post.Preview.Images[0].Source.Height
+		}
+		return []VideoInfo{info}, nil
+	}
+
+	return nil, fmt.Errorf("no media found in post %s", postID)
+}
+
+func (rp *RedditProvider) galleryInfos(post *redditPost, base VideoInfo, postID string, opts Options) ([]VideoInfo, error) {
+	if opts.AudioOnly {
+		return nil, ErrUnsupported
+	}
+
+	var results []VideoInfo
+	for i, item := range post.GalleryData.Items {
+		meta, ok := post.MediaMetadata[item.MediaID]
+		if !ok || meta.Status != "valid" {
+			continue
+		}
+
+		info := base
+		info.Width = meta.Source.Width
+		info.Height = meta.Source.Height
+		if item.Caption != "" {
+			info.Caption = item.Caption
+		}
+
+		switch {
+		case meta.Type == "AnimatedImage" && meta.Source.MP4 != "":
+			info.URL = html.UnescapeString(meta.Source.MP4)
+			info.FileName = fmt.Sprintf("reddit_%s_%d.mp4", postID, i)
+			info.MimeType = "video/mp4"
+			info.Animated = true
+		case meta.Source.URL != "":
+			info.URL = html.UnescapeString(meta.Source.URL)
+			ext := ".jpg"
+			if meta.MimeType == "image/png" {
+				ext = ".png"
+			}
+			info.FileName = fmt.Sprintf("reddit_%s_%d%s", postID, i, ext)
+			info.MimeType = "image/jpeg"
+			if ext == ".png" {
+				info.MimeType = "image/png"
+			}
+		default:
+			continue
+		}
+		results = append(results, info)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("empty gallery in post %s", postID)
+	}
+	return results, nil
+}
+
+// videoInfos merges the DASH video and audio renditions of a v.redd.it
+// video into a single MP4 stream.
+func (rp *RedditProvider) videoInfos(ctx context.Context, video *redditVideo, base VideoInfo, postID string, opts Options) ([]VideoInfo, error) {
+	info := base
+	info.URL = html.UnescapeString(video.FallbackURL)
+	info.Width = video.Width
+	info.Height = video.Height
+	info.Duration = video.Duration
+	info.FileName = fmt.Sprintf("reddit_%s.mp4", postID)
+	info.MimeType = "video/mp4"
+	info.Animated = video.IsGIF
+
+	if video.IsGIF {
+		if opts.AudioOnly {
+			return nil, ErrUnsupported
+		}
+		return []VideoInfo{info}, nil
+	}
+
+	audioURL, err := rp.dashAudioURL(ctx, html.UnescapeString(video.DashURL))
+	if err != nil || audioURL == "" {
+		// No audio track: the fallback MP4 is complete on its own
+		return []VideoInfo{info}, nil
+	}
+
+	if opts.AudioOnly {
+		info.URL = audioURL
+		info.FileName = fmt.Sprintf("reddit_%s.m4a", postID)
+		info.MimeType = "audio/mp4"
+		return []VideoInfo{info}, nil
+	}
+
+	info.Mux = &MuxSpec{
+		Inputs:   []string{info.URL, audioURL},
+		Mode:     "merge",
+		Metadata: map[string]string{"title": info.Title},
+	}
+	return []VideoInfo{info}, nil
+}
+
+// dashAudioURL returns the highest bandwidth audio representation from the
+// DASH manifest, or "" if the video has no sound.
+func (rp *RedditProvider) dashAudioURL(ctx context.Context, manifestURL string) (string, error) {
+	if manifestURL == "" {
+		return "", nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", manifestURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", redditUserAgent)
+
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errs.Status("reddit manifest", resp.StatusCode)
+	}
+
+	var mpd struct {
+		Periods []struct {
+			AdaptationSets []struct {
+				ContentType     string `xml:"contentType,attr"`
+				MimeType        string `xml:"mimeType,attr"`
+				Representations []struct {
+					Bandwidth int    `xml:"bandwidth,attr"`
+					MimeType  string `xml:"mimeType,attr"`
+					BaseURL   string `xml:"BaseURL"`
+				} `xml:"Representation"`
+			} `xml:"AdaptationSet"`
+		} `xml:"Period"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&mpd); err != nil {
+		return "", fmt.Errorf("decode manifest failed: %w", err)
+	}
+
+	best, bestBandwidth := "", -1
+	for _, period := range mpd.Periods {
+		for _, set := range period.AdaptationSets {
+			isAudio := set.ContentType == "audio" || strings.HasPrefix(set.MimeType, "audio/")
+			for _, rep := range set.Representations {
+				if !isAudio && !strings.HasPrefix(rep.MimeType, "audio/") {
+					continue
+				}
+				if rep.Bandwidth > bestBandwidth && rep.BaseURL != "" {
+					best, bestBandwidth = strings.TrimSpace(rep.BaseURL), rep.Bandwidth
+				}
+			}
+		}
+	}
+	if best == "" {
+		return "", nil
+	}
+
+	baseURL, err := url.Parse(manifestURL)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(best)
+	if err != nil {
+		return "", err
+	}
+	return baseURL.ResolveReference(ref).String(), nil
+}
+
+func (rp *RedditProvider) followRedirect(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("User-Agent", redditUserAgent)
+
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("resolve short link failed: %w", err)
+	}
+	resp.Body.Close()
+
+	return resp.Request.URL.String(), nil
+}
+
+func (rp *RedditProvider) fetchPost(ctx context.Context, postID string) (*redditPost, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://www.reddit.com/comments/"+postID+".json?raw_json=1", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("User-Agent", redditUserAgent)
+
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("reddit request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusForbidden:
+		return nil, ErrContentPrivate
+	case http.StatusNotFound:
+		return nil, ErrContentUnavailable
+	case http.StatusTooManyRequests:
+		return nil, ErrRateLimited
+	default:
+		return nil, errs.Status("reddit", resp.StatusCode)
+	}
+
+	var listings []struct {
+		Data struct {
+			Children []struct {
+				Data redditPost `json:"data"`
+			} `json:"children"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listings); err != nil {
+		return nil, fmt.Errorf("decode failed: %w", err)
+	}
+	if len(listings) == 0 || len(listings[0].Data.Children) == 0 {
+		return nil, ErrContentUnavailable
+	}
+	return &listings[0].Data.Children[0].Data, nil
+}
+
+type redditPost struct {
+	Title       string `json:"title"`
+	Selftext    string `json:"selftext"`
+	Author      string `json:"author"`
+	Subreddit   string `json:"subreddit"`
+	Ups         int64  `json:"ups"`
+	URL         string `json:"url_overridden_by_dest"`
+	PostHint    string `json:"post_hint"`
+	IsSelf      bool   `json:"is_self"`
+	IsGallery   bool   `json:"is_gallery"`
+	GalleryData struct {
+		Items []struct {
+			MediaID string `json:"media_id"`
+			Caption string `json:"caption"`
+		} `json:"items"`
+	} `json:"gallery_data"`
+	MediaMetadata map[string]struct {
+		Status   string `json:"status"`
+		Type     string `json:"e"` // Image or AnimatedImage
+		MimeType string `json:"m"`
+		Source   struct {
+			URL    string `json:"u"`
+			MP4    string `json:"mp4"`
+			Width  int    `json:"x"`
+			Height int    `json:"y"`
+		} `json:"s"`
+	} `json:"media_metadata"`
+	SecureMedia struct {
+		RedditVideo *redditVideo `json:"reddit_video"`
+	} `json:"secure_media"`
+	Preview struct {
+		Images []struct {
+			Source struct {
+				Width  int `json:"width"`
+				Height int `json:"height"`
+			} `json:"source"`
+		} `json:"images"`
+		RedditVideoPreview *redditVideo `json:"reddit_video_preview"`
+	} `json:"preview"`
+	CrosspostParentList []redditPost `json:"crosspost_parent_list"`
+}
+
+type redditVideo struct {
+	FallbackURL string `json:"fallback_url"`
+	DashURL     string `json:"dash_url"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Duration    int    `json:"duration"`
+	IsGIF       bool   `json:"is_gif"`
+}
@@ -5,10 +5,15 @@ import (
 	"fmt"
 	"net/url"
 	"sync"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
 )
 
 var (
 	registry = make([]Provider, 0)
+	plugins  = make(map[Provider]bool)
 	mu       sync.RWMutex
 )
 
@@ -19,8 +24,47 @@ func Register(p Provider) {
 	registry = append(registry, p)
 }
 
+// SetPlugins replaces all previously installed plugin providers with ps.
+// Plugins are consulted before the built-in providers so they can take over
+// sites the bot already knows.
+func SetPlugins(ps []Provider) {
+	mu.Lock()
+	defer mu.Unlock()
 
-func GetProvider(rawURL string) (Provider, error) {
+	builtin := make([]Provider, 0, len(registry))
+	for _, p := range registry {
+		if !plugins[p] {
+			builtin = append(builtin, p)
+		}
+	}
+
+	plugins = make(map[Provider]bool, len(ps))
+	for _, p := range ps {
+		plugins[p] = true
+	}
+	registry = append(append(make([]Provider, 0, len(ps)+len(builtin)), ps...), builtin...)
+}
+
+
+// Fallback is implemented by providers that claim some links by their shape
+// alone, e.g. a status URL on an unknown Mastodon host. Such claims are
+// only honoured when the user asked for a download explicitly with /dl.
+type Fallback interface {
+	Fallback(url string) bool
+}
+
+// claims reports whether p should handle url.
+func claims(p Provider, url string, explicit bool) bool {
+	if !p.Supports(url) {
+		return false
+	}
+	if f, ok := p.(Fallback); ok && !explicit {
+		return !f.Fallback(url)
+	}
+	return true
+}
+
+func GetProvider(rawURL string, explicit bool) (Provider, error) {
 
 	_, err := url.Parse(rawURL)
 	if err != nil {
@@ -31,12 +75,12 @@ func GetProvider(rawURL string) (Provider, error) {
 	defer mu.RUnlock()
 
 	for _, p := range registry {
-		if p.Supports(rawURL) {
+		if claims(p, rawURL, explicit) {
 			return p, nil
 		}
 	}
 
-	return nil, fmt.Errorf("no provider found for this URL")
+	return nil, errs.New(errs.Unsupported, "no provider found for this URL")
 }
 
 
@@ -44,28 +88,59 @@ func Resolve(ctx context.Context, url string, opts Options) ([]VideoInfo, string
 	mu.RLock()
 	var targets []Provider
 	for _, p := range registry {
-		if p.Supports(url) {
+		if claims(p, url, opts.Explicit) {
 			targets = append(targets, p)
 		}
 	}
 	mu.RUnlock()
 
 	if len(targets) == 0 {
-		return nil, "", fmt.Errorf("no provider found for this URL")
+		return nil, "", errs.New(errs.Unsupported, "no provider found for this URL")
 	}
 
 	var lastErr error
 	for _, p := range targets {
+		h := healthFor(p.Name())
+		_, canProbe := p.(Prober)
+		if !h.allow(canProbe) {
+			logger.Warn("Skipping provider, circuit open", "provider", p.Name())
+			continue
+		}
+
+		start := time.Now()
 		infos, err := p.GetVideoInfo(ctx, url, opts)
+		empty := err == nil && (len(infos) == 0 || infos[0].URL == "")
+		if ctx.Err() == nil {
+			// An empty answer usually means the provider broke, e.g. on a
+			// changed page layout, so it counts against its health
+			recordErr := err
+			if empty {
+				recordErr = errs.Errorf(errs.Internal, "%s returned no media", p.Name())
+			}
+			h.record(time.Since(start), recordErr)
+		} else {
+			h.abort()
+		}
+
 		if err == nil {
-			if len(infos) == 0 || infos[0].URL == "" {
-				lastErr = fmt.Errorf("%s returned no media", p.Name())
+			if empty {
+				lastErr = errs.Errorf(errs.NotFound, "%s returned no media", p.Name())
 				continue
 			}
+			if opts.Clip != nil {
+				infos, err = applyClip(infos, *opts.Clip)
+				if err != nil {
+					return nil, p.Name(), err
+				}
+			}
 			return infos, p.Name(), nil
 		}
 		lastErr = fmt.Errorf("%s failed: %w", p.Name(), err)
 	}
 
+	if lastErr == nil {
+		return nil, "", errs.New(errs.UpstreamDown, "all providers for this URL are temporarily unavailable")
+	}
+
 	return nil, "", lastErr
 }
@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+)
+
+const searchTimeout = 30 * time.Second
+
+var searchClient = &http.Client{Timeout: searchTimeout}
+
+// SearchResult is one video found by Search.
+type SearchResult struct {
+	ID       string
+	Title    string
+	Channel  string
+	Duration int // Seconds, 0 if unknown
+}
+
+// URL returns the watch link that the YouTube provider downloads.
+func (r SearchResult) URL() string {
+	return "https://www.youtube.com/watch?v=" + r.ID
+}
+
+// Thumbnail returns the YouTube thumbnail of the result.
+func (r SearchResult) Thumbnail() string {
+	return "https://i.ytimg.com/vi/" + r.ID + "/hqdefault.jpg"
+}
+
+// Search looks up query on YouTube, or on YouTube Music when music is set.
+// Video searches go to the configured Invidious instance if there is one
+// and to yt-dlp otherwise. Invidious has no music catalogue, so music
+// searches always use yt-dlp.
+func Search(ctx context.Context, query string, music bool, limit int) ([]SearchResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, searchTimeout)
+	defer cancel()
+
+	if backend := config.GetSearchBackend(); backend != "" && !music {
+		return searchInvidious(ctx, backend, query, limit)
+	}
+	return searchYtdlp(ctx, query, music, limit)
+}
+
+func searchYtdlp(ctx context.Context, query string, music bool, limit int) ([]SearchResult, error) {
+	// yt-dlp has ytsearchN: for YouTube; YouTube Music is searched through
+	// its search page, limited to the songs shelf
+	target := fmt.Sprintf("ytsearch%d:%s", limit, query)
+	if music {
+		target = "https://music.youtube.com/search?q=" + neturl.QueryEscape(query) + "#songs"
+	}
+	args := []string{"--flat-playlist", "-J", "--no-warnings", "--playlist-end", strconv.Itoa(limit), target}
+
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, errs.Wrap(errs.Timeout, "yt-dlp search", ctx.Err())
+		}
+		logger.Warn("yt-dlp search failed", "query", query, "error", err, "stderr", stderr.String())
+		return nil, errs.Wrap(errs.UpstreamDown, "yt-dlp search", err)
+	}
+
+	var playlist struct {
+		Entries []struct {
+			ID       string  `json:"id"`
+			Title    string  `json:"title"`
+			Channel  string  `json:"channel"`
+			Uploader string  `json:"uploader"`
+			Duration float64 `json:"duration"`
+		} `json:"entries"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &playlist); err != nil {
+		return nil, fmt.Errorf("decode search results failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(playlist.Entries))
+	for _, entry := range playlist.Entries {
+		if entry.ID == "" {
+			continue
+		}
+		channel := entry.Channel
+		if channel == "" {
+			channel = entry.Uploader
+		}
+		results = append(results, SearchResult{
+			ID:       entry.ID,
+			Title:    entry.Title,
+			Channel:  channel,
+			Duration: int(entry.Duration),
+		})
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+func searchInvidious(ctx context.Context, backend, query string, limit int) ([]SearchResult, error) {
+	endpoint := backend + "/api/v1/search?type=video&q=" + neturl.QueryEscape(query)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := searchClient.Do(req)
+	if err != nil {
+		return nil, errs.Network("invidious search", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errs.Status("invidious search", resp.StatusCode)
+	}
+
+	var items []struct {
+		Type          string `json:"type"`
+		VideoID       string `json:"videoId"`
+		Title         string `json:"title"`
+		Author        string `json:"author"`
+		LengthSeconds int    `json:"lengthSeconds"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		return nil, fmt.Errorf("decode search results failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, limit)
+	for _, item := range items {
+		if item.Type != "video" || item.VideoID == "" {
+			continue
+		}
+		results = append(results, SearchResult{
+			ID:       item.VideoID,
+			Title:    item.Title,
+			Channel:  item.Author,
+			Duration: item.LengthSeconds,
+		})
+		if len(results) == limit {
+			break
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SubtitleMime is the MIME type of subtitle files sent next to a video.
+const SubtitleMime = "application/x-subrip"
+
+// defaultSubtitleLang is used when --subs is given without a language.
+const defaultSubtitleLang = "en"
+
+// Subtitle is a subtitle track that is sent as an SRT file after the media.
+type Subtitle struct {
+	Lang string // Language code as listed by the site, e.g. "en" or "pt-BR"
+	URL  string // WebVTT (or other ffmpeg-readable) subtitle URL
+	Auto bool   // Automatically generated captions
+}
+
+type ytdlpSubtitle struct {
+	Ext  string `json:"ext"`
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+// pickSubtitle finds lang in the uploaded subtitles of meta, then in its
+// automatic captions. "en" also matches regional tracks like "en-US".
+func pickSubtitle(meta ytdlpMeta, lang string) *Subtitle {
+	if lang == "" || lang == "true" {
+		lang = defaultSubtitleLang
+	}
+	for _, auto := range []bool{false, true} {
+		tracks := meta.Subtitles
+		if auto {
+			tracks = meta.AutoCaptions
+		}
+		key := matchSubtitleLang(tracks, lang)
+		if key == "" {
+			continue
+		}
+		if url := subtitleURL(tracks[key]); url != "" {
+			return &Subtitle{Lang: key, URL: url, Auto: auto}
+		}
+	}
+	return nil
+}
+
+func matchSubtitleLang(tracks map[string][]ytdlpSubtitle, lang string) string {
+	for key := range tracks {
+		if strings.EqualFold(key, lang) {
+			return key
+		}
+	}
+	var matches []string
+	for key := range tracks {
+		if strings.HasPrefix(strings.ToLower(key), strings.ToLower(lang)+"-") {
+			matches = append(matches, key)
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Strings(matches)
+	return matches[0]
+}
+
+// subtitleURL prefers WebVTT, which ffmpeg converts to SRT reliably.
+func subtitleURL(formats []ytdlpSubtitle) string {
+	for _, f := range formats {
+		if f.Ext == "vtt" && f.URL != "" {
+			return f.URL
+		}
+	}
+	for _, f := range formats {
+		if f.Ext == "srt" && f.URL != "" {
+			return f.URL
+		}
+	}
+	return ""
+}
+
+// subtitleLangs returns the sorted language codes that have a usable track.
+func subtitleLangs(tracks map[string][]ytdlpSubtitle) []string {
+	langs := make([]string, 0, len(tracks))
+	for lang, formats := range tracks {
+		// yt-dlp lists live chat replays as a subtitle track
+		if lang == "live_chat" || subtitleURL(formats) == "" {
+			continue
+		}
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// SubtitleFile returns an item that converts the subtitle of info to SRT
+// with ffmpeg and sends it as a document.
+func SubtitleFile(info VideoInfo) VideoInfo {
+	sub := info.Subtitle
+	base := strings.TrimSuffix(info.FileName, filepath.Ext(info.FileName))
+	if base == "" {
+		base = "subtitles"
+	}
+	return VideoInfo{
+		FileName:   base + "." + sub.Lang + ".srt",
+		Title:      info.Title,
+		MimeType:   SubtitleMime,
+		Headers:    info.Headers,
+		AsDocument: true,
+		Mux: &MuxSpec{
+			Inputs: []string{sub.URL},
+			Mode:   "srt",
+		},
+	}
+}
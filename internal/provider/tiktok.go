@@ -6,17 +6,31 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+	"github.com/pavelc4/aether-tg-bot/pkg/logger"
 )
 
 const (
-	tikTokAPIURL  = "https://www.tikwm.com/api/"
-	tikTokTimeout = 30 * time.Second
+	tikTokTimeout     = 30 * time.Second
+	tikTokRateLimit   = time.Second // tikwm free tier: 1 request per second
+	tikTokMaxPageSize = 35
+)
+
+var (
+	tikTokCollectionRegex = regexp.MustCompile(`tiktok\.com/@([\w.-]+)/collection/[^/?#]*?-?(\d+)(?:[/?#]|$)`)
+	tikTokProfileRegex    = regexp.MustCompile(`tiktok\.com/@([\w.-]+)/?(?:[?#]|$)`)
 )
 
 type TikTokProvider struct {
-	client *http.Client
+	client  *http.Client
+	limiter *rateLimiter
 }
 
 func NewTikTok() *TikTokProvider {
@@ -24,6 +38,7 @@ func NewTikTok() *TikTokProvider {
 		client: &http.Client{
 			Timeout: tikTokTimeout,
 		},
+		limiter: newRateLimiter(tikTokRateLimit),
 	}
 }
 
@@ -36,69 +51,163 @@ func (tp *TikTokProvider) Supports(url string) bool {
 }
 
 func (tp *TikTokProvider) GetVideoInfo(ctx context.Context, url string, opts Options) ([]VideoInfo, error) {
-	resp, err := tp.fetchData(ctx, url)
+	if m := tikTokCollectionRegex.FindStringSubmatch(url); m != nil {
+		return tp.fetchPosts(ctx, "/api/collection/posts", "collection_id", m[2], opts)
+	}
+	if m := tikTokProfileRegex.FindStringSubmatch(url); m != nil {
+		return tp.fetchPosts(ctx, "/api/user/posts", "unique_id", m[1], opts)
+	}
+
+	resp, err := tp.fetchData(ctx, url, preferHD(opts))
 	if err != nil {
 		return nil, err
 	}
 
-	if opts.AudioOnly && resp.Data.Music != "" {
-		musicURL := resp.Data.Music
-		if !strings.HasPrefix(musicURL, "http") {
-			musicURL = "https://tikwm.com" + musicURL
+	return tp.toVideoInfos(resp.Data, opts)
+}
+
+// preferHD returns the HD preference, overridable per request with --hd.
+func preferHD(opts Options) bool {
+	if v, ok := opts.Flags["hd"]; ok {
+		if hd, err := strconv.ParseBool(v); err == nil {
+			return hd
 		}
-		return []VideoInfo{{
-			URL:      musicURL,
-			FileName: fmt.Sprintf("tiktok_audio_%s.mp3", resp.Data.ID),
-			Title:    resp.Data.Title,
-			FileSize: 0,
-			MimeType: "audio/mpeg",
-			Duration: resp.Data.Duration,
-		}}, nil
+	}
+	return config.GetTikTokHD()
+}
+
+func (tp *TikTokProvider) toVideoInfos(data tikWMData, opts Options) ([]VideoInfo, error) {
+	base := VideoInfo{
+		Title:     data.Title,
+		Author:    data.Author.displayName(),
+		Thumbnail: absoluteTikWMURL(data.Cover),
+		Views:     data.PlayCount,
+		Likes:     data.DiggCount,
+		Duration:  data.Duration,
+	}
+
+	if opts.AudioOnly && data.Music != "" {
+		info := base
+		info.URL = absoluteTikWMURL(data.Music)
+		info.FileName = fmt.Sprintf("tiktok_audio_%s.mp3", data.ID)
+		info.MimeType = "audio/mpeg"
+		return []VideoInfo{info}, nil
 	}
 
 	// Check for images (Slides) - THIS SHOULD BE THE PRIORITY
-	if len(resp.Data.Images) > 0 {
+	if len(data.Images) > 0 {
 		var results []VideoInfo
-		for i, imgURL := range resp.Data.Images {
-			// Ensure image URL is absolute
-			if !strings.HasPrefix(imgURL, "http") {
-				imgURL = "https://tikwm.com" + imgURL
-			}
-			results = append(results, VideoInfo{
-				URL:      imgURL,
-				FileName: fmt.Sprintf("tiktok_slide_%s_%d.jpg", resp.Data.ID, i),
-				Title:    resp.Data.Title,
-				MimeType: "image/jpeg",
-				Duration: 0,
-			})
+		for i, imgURL := range data.Images {
+			info := base
+			info.URL = absoluteTikWMURL(imgURL)
+			info.FileName = fmt.Sprintf("tiktok_slide_%s_%d.jpg", data.ID, i)
+			info.MimeType = "image/jpeg"
+			info.Duration = 0
+			results = append(results, info)
 		}
 		return results, nil
 	}
 
 	// Only process video if no images found
-	videoURL := resp.Data.Play
+	videoURL, size := data.Play, data.Size
+	if preferHD(opts) && data.HDPlay != "" {
+		videoURL, size = data.HDPlay, data.HDSize
+	}
 	if videoURL == "" {
 		return nil, fmt.Errorf("video URL not found in response")
 	}
-	if !strings.HasPrefix(videoURL, "http") {
-		videoURL = "https://tikwm.com" + videoURL
+
+	info := base
+	info.URL = absoluteTikWMURL(videoURL)
+	info.FileName = fmt.Sprintf("tiktok_%s.mp4", data.ID)
+	info.FileSize = int64(size) // TikWM provides size
+	info.MimeType = "video/mp4"
+	if opts.AudioOnly {
+		info.MimeType = "audio/mp4"
 	}
 
-	mime := "video/mp4"
-	filename := fmt.Sprintf("tiktok_%s.mp4", resp.Data.ID)
+	return []VideoInfo{info}, nil
+}
 
-	if opts.AudioOnly {
-		mime = "audio/mp4"
+// fetchPosts pages through a tikwm post listing (user posts or a
+// collection) until the configured cap is reached.
+func (tp *TikTokProvider) fetchPosts(ctx context.Context, endpoint, idParam, id string, opts Options) ([]VideoInfo, error) {
+	limit := config.GetTikTokMaxPosts()
+	cursor := "0"
+	var results []VideoInfo
+
+	for len(results) < limit {
+		count := limit - len(results)
+		if count > tikTokMaxPageSize {
+			count = tikTokMaxPageSize
+		}
+
+		form := url.Values{}
+		form.Set(idParam, id)
+		form.Set("count", strconv.Itoa(count))
+		form.Set("cursor", cursor)
+
+		var page tikWMPostsResponse
+		if err := tp.call(ctx, endpoint, form, &page); err != nil {
+			if len(results) > 0 {
+				logger.Warn("TikTok pagination stopped early", "endpoint", endpoint, "items", len(results), "error", err)
+				break
+			}
+			return nil, err
+		}
+
+		for _, post := range page.Data.Videos {
+			if post.ID == "" {
+				post.ID = post.VideoID
+			}
+			infos, err := tp.toVideoInfos(post, opts)
+			if err != nil {
+				continue
+			}
+			results = append(results, infos...)
+		}
+
+		if !page.Data.HasMore || page.Data.Cursor == "" || len(page.Data.Videos) == 0 {
+			break
+		}
+		cursor = page.Data.Cursor
+	}
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no posts found")
+	}
+
+	logger.Info("TikTok listing resolved", "endpoint", endpoint, "id", id, "items", len(results))
+	return results, nil
+}
+
+// Probe checks that tikwm.com is reachable.
+func (tp *TikTokProvider) Probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", config.GetTikTokAPIURL()+"/api/", nil)
+	if err != nil {
+		return fmt.Errorf("create request failed: %w", err)
+	}
+
+	resp, err := tp.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errs.Status("tiktok API", resp.StatusCode)
 	}
+	return nil
+}
 
-	return []VideoInfo{{
-		URL:      videoURL,
-		FileName: filename,
-		Title:    resp.Data.Title,
-		FileSize: int64(resp.Data.Size), // TikWM provides size
-		MimeType: mime,
-		Duration: resp.Data.Duration,
-	}}, nil
+func absoluteTikWMURL(u string) string {
+	if u == "" || strings.HasPrefix(u, "http") {
+		return u
+	}
+	return config.GetTikTokAPIURL() + u
 }
 
 type tikWMResponse struct {
@@ -107,47 +216,104 @@ type tikWMResponse struct {
 	Data tikWMData `json:"data"`
 }
 
+type tikWMPostsResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Videos  []tikWMData `json:"videos"`
+		Cursor  string      `json:"cursor"`
+		HasMore bool        `json:"hasMore"`
+	} `json:"data"`
+}
+
 type tikWMData struct {
-	ID       string   `json:"id"`
-	Title    string   `json:"title"`
-	Play     string   `json:"play"`   // Video URL
-	Music    string   `json:"music"`  // Audio URL
-	Images   []string `json:"images"` // Images for slides
-	Size     int      `json:"size"`
-	Duration int      `json:"duration"`
-}
-
-func (tp *TikTokProvider) fetchData(ctx context.Context, tiktokURL string) (*tikWMResponse, error) {
-	payload := map[string]string{"url": tiktokURL}
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return nil, fmt.Errorf("marshal failed: %w", err)
+	ID        string      `json:"id"`
+	VideoID   string      `json:"video_id"` // Used instead of id in listings
+	Title     string      `json:"title"`
+	Play      string      `json:"play"`   // Video URL
+	HDPlay    string      `json:"hdplay"` // HD video URL (only with hd=1)
+	Music     string      `json:"music"`  // Audio URL
+	Cover     string      `json:"cover"`
+	Images    []string    `json:"images"` // Images for slides
+	Size      int         `json:"size"`
+	HDSize    int         `json:"hd_size"`
+	Duration  int         `json:"duration"`
+	PlayCount int64       `json:"play_count"`
+	DiggCount int64       `json:"digg_count"`
+	Author    tikWMAuthor `json:"author"`
+}
+
+type tikWMAuthor struct {
+	UniqueID string `json:"unique_id"`
+	Nickname string `json:"nickname"`
+}
+
+func (a tikWMAuthor) displayName() string {
+	switch {
+	case a.Nickname != "" && a.UniqueID != "":
+		return fmt.Sprintf("%s (@%s)", a.Nickname, a.UniqueID)
+	case a.UniqueID != "":
+		return "@" + a.UniqueID
+	default:
+		return a.Nickname
 	}
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", tikTokAPIURL, bytes.NewBuffer(jsonPayload))
+func (tp *TikTokProvider) fetchData(ctx context.Context, tiktokURL string, hd bool) (*tikWMResponse, error) {
+	form := url.Values{}
+	form.Set("url", tiktokURL)
+	if hd {
+		form.Set("hd", "1")
+	}
+
+	var result tikWMResponse
+	if err := tp.call(ctx, "/api/", form, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// call posts a form to a tikwm endpoint, honouring the client-side rate
+// limit, and decodes the response into out.
+func (tp *TikTokProvider) call(ctx context.Context, endpoint string, form url.Values, out interface{}) error {
+	if err := tp.limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", config.GetTikTokAPIURL()+endpoint, bytes.NewBufferString(form.Encode()))
 	if err != nil {
-		return nil, fmt.Errorf("create request failed: %w", err)
+		return fmt.Errorf("create request failed: %w", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	resp, err := tp.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return errs.Network("tiktok API", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+		return errs.Status("tiktok API", resp.StatusCode)
 	}
 
-	var result tikWMResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decode failed: %w", err)
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("decode failed: %w", err)
 	}
 
-	if result.Code != 0 {
-		return nil, fmt.Errorf("API error: %s", result.Msg)
+	var status struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("decode failed: %w", err)
+	}
+	if status.Code != 0 {
+		return fmt.Errorf("tikwm %q: %w", status.Msg, classifyTikwmMessage(status.Msg))
 	}
 
-	return &result, nil
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("decode failed: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,236 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
+)
+
+const (
+	twitterTimeout        = 30 * time.Second
+	twitterSyndicationURL = "https://cdn.syndication.twimg.com/tweet-result"
+)
+
+var (
+	twitterHostRegex   = regexp.MustCompile(`(?:^|[/.])(?:twitter|x)\.com/`)
+	twitterStatusRegex = regexp.MustCompile(`(?:twitter|x)\.com/[^/]+/status(?:es)?/(\d+)`)
+	twitterTcoRegex    = regexp.MustCompile(`\s*https://t\.co/\w+$`)
+)
+
+type TwitterProvider struct {
+	client *http.Client
+}
+
+func NewTwitter() *TwitterProvider {
+	return &TwitterProvider{
+		client: &http.Client{
+			Timeout: twitterTimeout,
+		},
+	}
+}
+
+func (xp *TwitterProvider) Name() string {
+	return "X"
+}
+
+func (xp *TwitterProvider) Supports(url string) bool {
+	return twitterHostRegex.MatchString(url)
+}
+
+func (xp *TwitterProvider) GetVideoInfo(ctx context.Context, url string, opts Options) ([]VideoInfo, error) {
+	m := twitterStatusRegex.FindStringSubmatch(url)
+	if m == nil {
+		return nil, ErrUnsupported
+	}
+	id := m[1]
+
+	tweet, err := xp.fetchTweet(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	text := tweet.text()
+	base := VideoInfo{
+		Title:   text,
+		Caption: text,
+		Author:  fmt.Sprintf("%s (@%s)", tweet.User.Name, tweet.User.ScreenName),
+		Likes:   tweet.FavoriteCount,
+	}
+	if text == "" {
+		base.Title = "Post by @" + tweet.User.ScreenName
+	}
+
+	var results []VideoInfo
+	for i, media := range tweet.MediaDetails {
+		info := base
+		info.Width = media.OriginalInfo.Width
+		info.Height = media.OriginalInfo.Height
+
+		switch media.Type {
+		case "photo":
+			if opts.AudioOnly {
+				continue
+			}
+			info.URL = media.MediaURL + "?name=orig"
+			info.FileName = fmt.Sprintf("x_%s_%d.jpg", id, i)
+			info.MimeType = "image/jpeg"
+
+		case "video", "animated_gif":
+			variant := media.VideoInfo.bestMP4()
+			if variant == "" {
+				continue
+			}
+			if media.Type == "animated_gif" {
+				if opts.AudioOnly {
+					continue
+				}
+				info.Animated = true
+			}
+			info.URL = variant
+			info.Thumbnail = media.MediaURL
+			info.Duration = media.VideoInfo.DurationMillis / 1000
+			info.FileName = fmt.Sprintf("x_%s_%d.mp4", id, i)
+			info.MimeType = "video/mp4"
+			if opts.AudioOnly {
+				info.MimeType = "audio/mp4"
+			}
+
+		default:
+			continue
+		}
+		results = append(results, info)
+	}
+
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no media found in post %s", id)
+	}
+	return results, nil
+}
+
+func (xp *TwitterProvider) fetchTweet(ctx context.Context, id string) (*syndicationTweet, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", twitterSyndicationURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+	q := req.URL.Query()
+	q.Set("id", id)
+	q.Set("lang", "en")
+	q.Set("token", syndicationToken(id))
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := xp.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("syndication request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrContentUnavailable
+	case http.StatusTooManyRequests:
+		return nil, ErrRateLimited
+	default:
+		return nil, errs.Status("twitter syndication", resp.StatusCode)
+	}
+
+	var tweet syndicationTweet
+	if err := json.NewDecoder(resp.Body).Decode(&tweet); err != nil {
+		return nil, fmt.Errorf("decode failed: %w", err)
+	}
+
+	switch tweet.Typename {
+	case "Tweet":
+		return &tweet, nil
+	case "TweetTombstone":
+		return nil, ErrContentUnavailable
+	default:
+		return nil, ErrContentPrivate
+	}
+}
+
+// syndicationToken reproduces the token the embed widget sends:
+// ((id / 1e15) * PI).toString(36) with zeros and the dot removed.
+func syndicationToken(id string) string {
+	n, err := strconv.ParseFloat(id, 64)
+	if err != nil {
+		return ""
+	}
+	v := n / 1e15 * math.Pi
+
+	intPart := math.Floor(v)
+	frac := v - intPart
+	var sb strings.Builder
+	sb.WriteString(strconv.FormatInt(int64(intPart), 36))
+	for i := 0; i < 11 && frac > 0; i++ {
+		frac *= 36
+		digit := int64(frac)
+		sb.WriteString(strconv.FormatInt(digit, 36))
+		frac -= float64(digit)
+	}
+
+	return strings.ReplaceAll(sb.String(), "0", "")
+}
+
+type syndicationTweet struct {
+	Typename         string `json:"__typename"`
+	Text             string `json:"text"`
+	DisplayTextRange []int  `json:"display_text_range"`
+	FavoriteCount    int64  `json:"favorite_count"`
+	User             struct {
+		Name       string `json:"name"`
+		ScreenName string `json:"screen_name"`
+	} `json:"user"`
+	MediaDetails []struct {
+		Type         string `json:"type"` // photo, video, animated_gif
+		MediaURL     string `json:"media_url_https"`
+		OriginalInfo struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"original_info"`
+		VideoInfo syndicationVideo `json:"video_info"`
+	} `json:"mediaDetails"`
+}
+
+// text returns the tweet text without the trailing t.co media link.
+func (t *syndicationTweet) text() string {
+	text := t.Text
+	if len(t.DisplayTextRange) == 2 {
+		runes := []rune(text)
+		start, end := t.DisplayTextRange[0], t.DisplayTextRange[1]
+		if start >= 0 && end <= len(runes) && start <= end {
+			text = string(runes[start:end])
+		}
+	}
+	return strings.TrimSpace(twitterTcoRegex.ReplaceAllString(text, ""))
+}
+
+type syndicationVideo struct {
+	DurationMillis int `json:"duration_millis"`
+	Variants       []struct {
+		Bitrate     int    `json:"bitrate"`
+		ContentType string `json:"content_type"`
+		URL         string `json:"url"`
+	} `json:"variants"`
+}
+
+func (v syndicationVideo) bestMP4() string {
+	best, bestRate := "", -1
+	for _, variant := range v.Variants {
+		if variant.ContentType != "video/mp4" {
+			continue
+		}
+		if variant.Bitrate > bestRate {
+			best, bestRate = variant.URL, variant.Bitrate
+		}
+	}
+	return best
+}
@@ -5,12 +5,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/pavelc4/aether-tg-bot/config"
+	"github.com/pavelc4/aether-tg-bot/internal/cookies"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
 	"github.com/pavelc4/aether-tg-bot/pkg/logger"
 )
 
@@ -49,13 +50,10 @@ func (yp *YouTubeProvider) GetVideoInfo(ctx context.Context, url string, opts Op
 		url,
 	}
 
-	if cookies := config.GetYtdlpCookies(); cookies != "" {
-		if _, err := os.Stat(cookies); err == nil {
-			logger.Info("Using yt-dlp cookies", "path", cookies)
-			args = append(args, "--cookies", cookies)
-		} else {
-			logger.Warn("Cookies file not found", "path", cookies)
-		}
+	jar := cookies.ForURL(url)
+	if jar != nil {
+		logger.Info("Using yt-dlp cookies", "path", jar.Path)
+		args = append(args, "--cookies", jar.Path)
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, youtubeTimeout)
@@ -67,7 +65,21 @@ func (yp *YouTubeProvider) GetVideoInfo(ctx context.Context, url string, opts Op
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("yt-dlp failed: %w (stderr: %s)", err, stderr.String())
+		cookieFile := ""
+		if jar != nil {
+			cookieFile = jar.Path
+		}
+		if ytErr := classifyYtdlpStderr(stderr.String(), cookieFile); ytErr != nil {
+			if IsAuthError(ytErr) {
+				cookies.MarkBad(jar, ytErr.Error())
+			}
+			return nil, ytErr
+		}
+		logger.Warn("yt-dlp failed", "url", url, "error", err, "stderr", stderr.String())
+		if ctx.Err() != nil {
+			return nil, errs.Wrap(errs.Timeout, "yt-dlp", ctx.Err())
+		}
+		return nil, fmt.Errorf("yt-dlp failed: %w", ErrUpstreamFailed)
 	}
 
 	var meta ytdlpMeta
@@ -75,6 +87,19 @@ func (yp *YouTubeProvider) GetVideoInfo(ctx context.Context, url string, opts Op
 		return nil, fmt.Errorf("decode json failed: %w", err)
 	}
 
+	// A live stream would be piped until youtubeTimeout; /record handles it
+	if meta.IsLive || meta.LiveStatus == "is_live" || meta.LiveStatus == "is_upcoming" {
+		return nil, ErrLiveStream
+	}
+
+	if opts.AudioOnly && opts.Flags["chapters"] == "true" {
+		if tracks := chapterTracks(meta); len(tracks) > 0 {
+			logger.Info("Splitting audio by chapters", "title", meta.Title, "chapters", len(tracks))
+			return tracks, nil
+		}
+		logger.Info("No chapters found, sending the whole track", "title", meta.Title)
+	}
+
 	usePipe := true
 	finalURL := url
 
@@ -111,29 +136,40 @@ func (yp *YouTubeProvider) GetVideoInfo(ctx context.Context, url string, opts Op
 		}
 	}
 
-	return []VideoInfo{{
-		URL:      finalURL,
-		FileName: filename,
-		Title:    meta.Title,
-		FileSize: size,
-		MimeType: mime,
-		Duration: int(meta.Duration),
-		Width:    meta.Width,
-		Height:   meta.Height,
-		Headers:  meta.HttpHeaders,
-		UsePipe:  usePipe,
-	}}, nil
-}
-
-func isNonStreamableURL(url string) bool {
-	lower := strings.ToLower(url)
-	imgExts := []string{".jpg", ".jpeg", ".png", ".webp"}
-	for _, ext := range imgExts {
-		if strings.Contains(lower, ext) {
-			return true
+	infos := []VideoInfo{{
+		URL:          finalURL,
+		FileName:     filename,
+		Title:        meta.Title,
+		Author:       meta.Uploader,
+		FileSize:     size,
+		MimeType:     mime,
+		Duration:     int(meta.Duration),
+		Width:        meta.Width,
+		Height:       meta.Height,
+		Headers:      meta.HttpHeaders,
+		UsePipe:      usePipe,
+		Formats:      youtubeFormats(meta),
+		SubLangs:     subtitleLangs(meta.Subtitles),
+		CaptionLangs: subtitleLangs(meta.AutoCaptions),
+	}}
+	if jar != nil {
+		infos[0].CookieFile = jar.Path
+	}
+	if q, ok := opts.Flags["quality"]; ok && !opts.AudioOnly {
+		if height, err := strconv.Atoi(strings.TrimSuffix(q, "p")); err == nil && height > 0 {
+			infos[0] = WithQuality(infos[0], height)
+		} else {
+			logger.Warn("Ignoring invalid quality", "quality", q)
+		}
+	}
+	if lang, ok := opts.Flags["subs"]; ok && !opts.AudioOnly {
+		if sub := pickSubtitle(meta, lang); sub != nil {
+			infos[0].Subtitle = sub
+		} else {
+			logger.Info("No subtitles in requested language", "title", meta.Title, "lang", lang)
 		}
 	}
-	return false
+	return infos, nil
 }
 
 type ytdlpMeta struct {
@@ -149,12 +185,29 @@ type ytdlpMeta struct {
 	TBR         float64           `json:"tbr,omitempty"`
 	HttpHeaders map[string]string `json:"http_headers"`
 	Formats     []ytdlpFormat     `json:"formats"`
+	Uploader    string            `json:"uploader"`
+	IsLive      bool              `json:"is_live"`
+	LiveStatus  string            `json:"live_status"`
+	Chapters    []ytdlpChapter    `json:"chapters"`
+
+	Subtitles    map[string][]ytdlpSubtitle `json:"subtitles"`
+	AutoCaptions map[string][]ytdlpSubtitle `json:"automatic_captions"`
+}
+
+type ytdlpChapter struct {
+	Title     string  `json:"title"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
 }
 
 type ytdlpFormat struct {
-	ID     string `json:"format_id"`
-	URL    string `json:"url"`
-	Ext    string `json:"ext"`
-	ACodec string `json:"acodec"`
-	VCodec string `json:"vcodec"`
+	ID          string  `json:"format_id"`
+	URL         string  `json:"url"`
+	Ext         string  `json:"ext"`
+	ACodec      string  `json:"acodec"`
+	VCodec      string  `json:"vcodec"`
+	Height      int     `json:"height"`
+	FileSize    int64   `json:"filesize,omitempty"`
+	FileSizeApp int64   `json:"filesize_approx,omitempty"`
+	TBR         float64 `json:"tbr,omitempty"`
 }
@@ -9,8 +9,10 @@ import (
 	"time"
 
 	"github.com/pavelc4/aether-tg-bot/pkg/buffer"
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
 	pkghttp "github.com/pavelc4/aether-tg-bot/pkg/http"
 	"github.com/pavelc4/aether-tg-bot/pkg/logger"
+	"github.com/pavelc4/aether-tg-bot/pkg/mediatype"
 )
 
 type Pipeline struct {
@@ -38,9 +40,9 @@ func (p *Pipeline) Start(ctx context.Context, input StreamInput, state *StreamSt
 		body = input.Reader
 		size = input.Size
 	} else {
-		body, size, _, err = pkghttp.StreamRequest(ctx, input.URL, input.Headers)
+		body, size, _, err = pkghttp.StreamRequest(ctx, input.URL, input.Headers, input.PublicOnly)
 		if err != nil {
-			return 0, "", fmt.Errorf("stream open failed: %w", err)
+			return 0, "", errs.Default(errs.UpstreamDown, "stream open failed", err)
 		}
 	}
 	defer body.Close()
@@ -58,9 +60,14 @@ func (p *Pipeline) Start(ctx context.Context, input StreamInput, state *StreamSt
 		}
 		state.mu.Unlock()
 	} else {
-		logger.Error("Stream size unknown, MTProto upload would fail", "url", input.URL)
-		return 0, "", fmt.Errorf("unknown stream size (required for MTProto)")
+		// Unknown size: parts are sent with an open-ended count and the
+		// real total is attached to the last part once the stream ends.
+		logger.Info("Stream size unknown, streaming with open part count", "file", input.Filename)
+		state.mu.Lock()
+		state.TotalParts = -1
+		state.mu.Unlock()
 	}
+	unknownSize := state.TotalParts < 0
 
 	chunkChan := make(chan Chunk, p.config.BufferSize)
 	errChan := make(chan error, 1)
@@ -70,6 +77,9 @@ func (p *Pipeline) Start(ctx context.Context, input StreamInput, state *StreamSt
 	defer cancel()
 
 	numWorkers := p.config.MinUploadWorkers
+	if unknownSize && p.config.UploadWorkers > numWorkers {
+		numWorkers = p.config.UploadWorkers
+	}
 	if state.TotalSize > 0 {
 		parts := state.TotalParts
 		if parts > 0 {
@@ -119,7 +129,7 @@ func (p *Pipeline) Start(ctx context.Context, input StreamInput, state *StreamSt
 
 				if err != nil {
 					select {
-					case errChan <- fmt.Errorf("worker %d failed to upload part %d after %d attempts: %w", id, chunk.PartNum, attempt+1, err):
+					case errChan <- errs.Errorf(errs.Internal, "worker %d failed to upload part %d after %d attempts: %w", id, chunk.PartNum, attempt+1, err):
 						cancel()
 					default:
 					}
@@ -146,6 +156,23 @@ func (p *Pipeline) Start(ctx context.Context, input StreamInput, state *StreamSt
 
 		hasher := md5.New()
 		partNum := 0
+
+		// The start of the stream is kept until ProbeMedia has what it needs
+		probing := false
+		var probeBuf []byte
+
+		send := func(chunk Chunk) bool {
+			select {
+			case chunkChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// With unknown size every chunk is held back until the next read
+		// shows whether it was the last one.
+		var pending *Chunk
 		for {
 			if ctx.Err() != nil {
 				return
@@ -162,13 +189,45 @@ func (p *Pipeline) Start(ctx context.Context, input StreamInput, state *StreamSt
 				// Write to haser
 				hasher.Write(buf[:n])
 
-				select {
-				case chunkChan <- Chunk{PartNum: partNum, TotalParts: state.TotalParts, Data: buf[:n], Size: n}:
-					partNum++
-					totalParts = partNum
-				case <-ctx.Done():
+				if partNum == 0 {
+					// Decided before the first part is uploaded, since it
+					// picks between small and big file parts
+					media := mediatype.Classify(buf[:n], input.MIME, input.Filename, state.TotalSize, input.AsDocument, input.AudioOnly)
+					logger.Info("Classified media",
+						"file", input.Filename,
+						"declared", input.MIME,
+						"mime", media.MIME,
+						"kind", media.Kind,
+						"big", media.Big,
+					)
+					if input.Media != nil {
+						*input.Media = media
+					}
+					probing = input.Probe != nil && (media.Kind == mediatype.Video || media.Kind == mediatype.Audio)
+				}
+
+				if probing {
+					probeBuf = append(probeBuf, buf[:n]...)
+					if probe, done := ProbeMedia(probeBuf); done {
+						*input.Probe = probe
+						probing, probeBuf = false, nil
+					} else if len(probeBuf) >= probeLimit {
+						logger.Info("Media probe gave up", "file", input.Filename, "bytes", len(probeBuf))
+						probing, probeBuf = false, nil
+					}
+				}
+
+				chunk := Chunk{PartNum: partNum, TotalParts: state.TotalParts, Data: buf[:n], Size: n}
+				if unknownSize {
+					if pending != nil && !send(*pending) {
+						return
+					}
+					pending = &chunk
+				} else if !send(chunk) {
 					return
 				}
+				partNum++
+				totalParts = partNum
 			}
 
 			if readErr != nil {
@@ -176,13 +235,25 @@ func (p *Pipeline) Start(ctx context.Context, input StreamInput, state *StreamSt
 					break
 				}
 				select {
-				case errChan <- fmt.Errorf("read failed: %w", readErr):
+				case errChan <- errs.Default(errs.UpstreamDown, "read failed", readErr):
 					cancel()
 				default:
 				}
 				return
 			}
 		}
+
+		if probing {
+			// Short streams end before the probe is sure; keep what it found
+			*input.Probe, _ = ProbeMedia(probeBuf)
+		}
+
+		if pending != nil {
+			pending.TotalParts = partNum
+			if !send(*pending) {
+				return
+			}
+		}
 		md5Result = fmt.Sprintf("%x", hasher.Sum(nil))
 	}()
 
@@ -197,7 +268,7 @@ func (p *Pipeline) Start(ctx context.Context, input StreamInput, state *StreamSt
 	}
 
 	if totalParts == 0 {
-		return 0, "", fmt.Errorf("stream returned no data")
+		return 0, "", errs.Errorf(errs.UpstreamDown, "stream returned no data")
 	}
 
 	return totalParts, md5Result, nil
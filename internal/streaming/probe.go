@@ -0,0 +1,432 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// probeLimit is how much of the start of a stream is kept for ProbeMedia.
+// Faststart MP4 files keep moov at the front; it is rarely this large.
+const probeLimit = 8 * 1024 * 1024
+
+// MediaProbe is what ProbeMedia found about the video track of a stream.
+// Zero fields were not found.
+type MediaProbe struct {
+	Width    int     // Display width, after rotation
+	Height   int     // Display height, after rotation
+	Duration float64 // Seconds
+	Rotation int     // Clockwise degrees: 0, 90, 180 or 270
+}
+
+// ProbeMedia reads video metadata from the start of an MP4 or Matroska
+// stream. done is false while more data could still reveal it.
+func ProbeMedia(data []byte) (probe MediaProbe, done bool) {
+	switch {
+	case len(data) < 12:
+		return probe, false
+	case bytes.Equal(data[4:8], []byte("ftyp")):
+		return probeMP4(data)
+	case bytes.Equal(data[:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return probeMatroska(data)
+	default:
+		return probe, true
+	}
+}
+
+// probeMP4 looks for the moov box before the first mdat or moof.
+func probeMP4(data []byte) (MediaProbe, bool) {
+	pos := 0
+	for {
+		typ, hdr, size, ok := mp4BoxHeader(data[pos:])
+		if !ok {
+			return MediaProbe{}, false
+		}
+		if size == 0 {
+			// Box runs to the end of the file: nothing after it to find
+			return MediaProbe{}, true
+		}
+		switch typ {
+		case "moov":
+			if pos+size > len(data) {
+				return MediaProbe{}, size > probeLimit
+			}
+			return parseMoov(data[pos+hdr : pos+size]), true
+		case "mdat", "moof":
+			// moov comes after the media data and is out of reach
+			return MediaProbe{}, true
+		}
+		pos += size
+		if pos >= len(data) {
+			return MediaProbe{}, false
+		}
+	}
+}
+
+// mp4BoxHeader returns the type, header length and total size of the box at
+// the start of data. size is 0 for a box that extends to the end of the file.
+func mp4BoxHeader(data []byte) (typ string, hdr, size int, ok bool) {
+	if len(data) < 8 {
+		return "", 0, 0, false
+	}
+	size = int(binary.BigEndian.Uint32(data))
+	typ = string(data[4:8])
+	hdr = 8
+	if size == 1 {
+		if len(data) < 16 {
+			return "", 0, 0, false
+		}
+		large := binary.BigEndian.Uint64(data[8:])
+		if large > math.MaxInt32 {
+			large = math.MaxInt32
+		}
+		size, hdr = int(large), 16
+	}
+	if size != 0 && size < hdr {
+		return "", 0, 0, false
+	}
+	return typ, hdr, size, true
+}
+
+// mp4Children calls fn for every complete box in data.
+func mp4Children(data []byte, fn func(typ string, payload []byte)) {
+	for pos := 0; pos < len(data); {
+		typ, hdr, size, ok := mp4BoxHeader(data[pos:])
+		if !ok {
+			return
+		}
+		if size == 0 || pos+size > len(data) {
+			size = len(data) - pos
+		}
+		fn(typ, data[pos+hdr:pos+size])
+		pos += size
+	}
+}
+
+type mp4Track struct {
+	video     bool
+	width     int
+	height    int
+	rotation  int
+	timescale uint32
+	duration  uint64
+}
+
+func parseMoov(moov []byte) MediaProbe {
+	var movieScale uint32
+	var movieDuration uint64
+	var video *mp4Track
+
+	mp4Children(moov, func(typ string, payload []byte) {
+		switch typ {
+		case "mvhd":
+			movieScale, movieDuration = mp4Times(payload)
+		case "mvex":
+			// Fragmented files may carry the total length here
+			mp4Children(payload, func(typ string, payload []byte) {
+				if typ != "mehd" || len(payload) < 8 || movieDuration != 0 {
+					return
+				}
+				if payload[0] == 1 && len(payload) >= 12 {
+					movieDuration = binary.BigEndian.Uint64(payload[4:])
+				} else {
+					movieDuration = uint64(binary.BigEndian.Uint32(payload[4:]))
+				}
+			})
+		case "trak":
+			if t := parseTrak(payload); t.video && video == nil {
+				video = &t
+			}
+		}
+	})
+
+	var probe MediaProbe
+	if video == nil {
+		if movieScale > 0 {
+			probe.Duration = float64(movieDuration) / float64(movieScale)
+		}
+		return probe
+	}
+
+	probe.Width, probe.Height, probe.Rotation = video.width, video.height, video.rotation
+	if probe.Rotation == 90 || probe.Rotation == 270 {
+		probe.Width, probe.Height = probe.Height, probe.Width
+	}
+	switch {
+	case video.timescale > 0 && video.duration > 0:
+		probe.Duration = float64(video.duration) / float64(video.timescale)
+	case movieScale > 0:
+		probe.Duration = float64(movieDuration) / float64(movieScale)
+	}
+	return probe
+}
+
+func parseTrak(trak []byte) mp4Track {
+	var t mp4Track
+	mp4Children(trak, func(typ string, payload []byte) {
+		switch typ {
+		case "tkhd":
+			t.width, t.height, t.rotation = parseTkhd(payload)
+		case "mdia":
+			mp4Children(payload, func(typ string, payload []byte) {
+				switch typ {
+				case "mdhd":
+					t.timescale, t.duration = mp4Times(payload)
+				case "hdlr":
+					// version/flags, pre_defined, then the handler type
+					if len(payload) >= 12 && string(payload[8:12]) == "vide" {
+						t.video = true
+					}
+				}
+			})
+		}
+	})
+	return t
+}
+
+// mp4Times reads timescale and duration from an mvhd or mdhd payload.
+func mp4Times(payload []byte) (uint32, uint64) {
+	if len(payload) < 4 {
+		return 0, 0
+	}
+	if payload[0] == 1 {
+		// version, flags, creation and modification times (64-bit)
+		if len(payload) < 32 {
+			return 0, 0
+		}
+		return binary.BigEndian.Uint32(payload[20:]), binary.BigEndian.Uint64(payload[24:])
+	}
+	if len(payload) < 20 {
+		return 0, 0
+	}
+	return binary.BigEndian.Uint32(payload[12:]), uint64(binary.BigEndian.Uint32(payload[16:]))
+}
+
+// parseTkhd reads the presentation size and rotation matrix of a track.
+func parseTkhd(payload []byte) (width, height, rotation int) {
+	// Offset of the matrix: after version/flags, times, track ID, reserved,
+	// duration, reserved, layer, alternate group, volume and reserved
+	off := 40
+	if len(payload) > 0 && payload[0] == 1 {
+		off = 52
+	}
+	if len(payload) < off+44 {
+		return 0, 0, 0
+	}
+
+	m := payload[off : off+36]
+	a := int32(binary.BigEndian.Uint32(m[0:]))
+	b := int32(binary.BigEndian.Uint32(m[4:]))
+	c := int32(binary.BigEndian.Uint32(m[12:]))
+	d := int32(binary.BigEndian.Uint32(m[16:]))
+	const one = 1 << 16
+	switch {
+	case a == 0 && b == one && c == -one && d == 0:
+		rotation = 90
+	case a == -one && b == 0 && c == 0 && d == -one:
+		rotation = 180
+	case a == 0 && b == -one && c == one && d == 0:
+		rotation = 270
+	}
+
+	// 16.16 fixed point
+	width = int(binary.BigEndian.Uint32(payload[off+36:]) >> 16)
+	height = int(binary.BigEndian.Uint32(payload[off+40:]) >> 16)
+	return width, height, rotation
+}
+
+// Matroska element IDs, with their length marker bits.
+const (
+	mkvSegment       = 0x18538067
+	mkvInfo          = 0x1549A966
+	mkvTimecodeScale = 0x2AD7B1
+	mkvDuration      = 0x4489
+	mkvTracks        = 0x1654AE6B
+	mkvTrackEntry    = 0xAE
+	mkvTrackType     = 0x83
+	mkvVideo         = 0xE0
+	mkvPixelWidth    = 0xB0
+	mkvPixelHeight   = 0xBA
+	mkvCluster       = 0x1F43B675
+)
+
+// probeMatroska reads the segment Info and Tracks elements, which muxers
+// write before the first Cluster.
+func probeMatroska(data []byte) (MediaProbe, bool) {
+	var probe MediaProbe
+	scale := uint64(1000000)
+	var duration float64
+	gotInfo, gotTracks := false, false
+
+	finish := func() (MediaProbe, bool) {
+		probe.Duration = duration * float64(scale) / 1e9
+		return probe, true
+	}
+
+	pos := 0
+	for pos < len(data) {
+		id, idLen := ebmlID(data[pos:])
+		if idLen == 0 {
+			return probe, false
+		}
+		size, sizeLen, unknown := ebmlSize(data[pos+idLen:])
+		if sizeLen == 0 {
+			return probe, false
+		}
+		body := pos + idLen + sizeLen
+
+		switch id {
+		case mkvSegment:
+			// Descend; live streams write the segment with an unknown size
+			pos = body
+			continue
+		case mkvCluster:
+			return finish()
+		}
+		if unknown {
+			return finish()
+		}
+		end := body + int(size)
+		if size > uint64(probeLimit) || end < body {
+			return finish()
+		}
+
+		switch id {
+		case mkvInfo, mkvTracks:
+			if end > len(data) {
+				return probe, false
+			}
+			if id == mkvInfo {
+				gotInfo = true
+				ebmlChildren(data[body:end], func(id uint32, payload []byte) {
+					switch id {
+					case mkvTimecodeScale:
+						if v := ebmlUint(payload); v > 0 {
+							scale = v
+						}
+					case mkvDuration:
+						duration = ebmlFloat(payload)
+					}
+				})
+			} else {
+				gotTracks = true
+				probe.Width, probe.Height = mkvVideoSize(data[body:end])
+			}
+			if gotInfo && gotTracks {
+				return finish()
+			}
+		}
+		pos = end
+	}
+	return probe, false
+}
+
+// mkvVideoSize returns the pixel size of the first video track.
+func mkvVideoSize(tracks []byte) (width, height int) {
+	ebmlChildren(tracks, func(id uint32, entry []byte) {
+		if id != mkvTrackEntry || width > 0 {
+			return
+		}
+		var isVideo bool
+		var w, h uint64
+		ebmlChildren(entry, func(id uint32, payload []byte) {
+			switch id {
+			case mkvTrackType:
+				isVideo = ebmlUint(payload) == 1
+			case mkvVideo:
+				ebmlChildren(payload, func(id uint32, payload []byte) {
+					switch id {
+					case mkvPixelWidth:
+						w = ebmlUint(payload)
+					case mkvPixelHeight:
+						h = ebmlUint(payload)
+					}
+				})
+			}
+		})
+		if isVideo {
+			width, height = int(w), int(h)
+		}
+	})
+	return width, height
+}
+
+// ebmlChildren calls fn for every complete child element in data.
+func ebmlChildren(data []byte, fn func(id uint32, payload []byte)) {
+	for pos := 0; pos < len(data); {
+		id, idLen := ebmlID(data[pos:])
+		if idLen == 0 {
+			return
+		}
+		size, sizeLen, unknown := ebmlSize(data[pos+idLen:])
+		if sizeLen == 0 || unknown {
+			return
+		}
+		body := pos + idLen + sizeLen
+		end := body + int(size)
+		if size > uint64(len(data)) || end > len(data) {
+			return
+		}
+		fn(id, data[body:end])
+		pos = end
+	}
+}
+
+// ebmlID reads an element ID, keeping its length marker as the spec does.
+func ebmlID(data []byte) (uint32, int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	n := 1
+	for mask := byte(0x80); n <= 4 && data[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 4 || len(data) < n {
+		return 0, 0
+	}
+	var id uint32
+	for _, b := range data[:n] {
+		id = id<<8 | uint32(b)
+	}
+	return id, n
+}
+
+// ebmlSize reads an element data size. unknown is set for the reserved
+// all-ones value used by streaming muxers.
+func ebmlSize(data []byte) (size uint64, n int, unknown bool) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0, false
+	}
+	n = 1
+	for mask := byte(0x80); data[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if len(data) < n {
+		return 0, 0, false
+	}
+	size = uint64(data[0] & (0xFF >> n))
+	allOnes := size == uint64(0xFF>>n)
+	for _, b := range data[1:n] {
+		size = size<<8 | uint64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	return size, n, allOnes
+}
+
+func ebmlUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func ebmlFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
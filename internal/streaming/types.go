@@ -4,6 +4,8 @@ import (
 	"context"
 	"io"
 	"sync"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/mediatype"
 )
 
 type Config struct {
@@ -25,24 +27,32 @@ type Chunk struct {
 
 type StreamState struct {
 	mu            sync.Mutex
-	FileID        int64           // Telegram File ID (generated)
-	TotalParts    int             // Estimated total parts
-	TotalSize     int64           // Total file size
-	UploadedParts map[int]bool    // Map of uploaded parts
-	ChunkRetries  map[int]int     // Retry count per part
-	IsCompleted   bool            // Upload completed
+	FileID        int64        // Telegram File ID (generated)
+	TotalParts    int          // Estimated total parts
+	TotalSize     int64        // Total file size
+	UploadedParts map[int]bool // Map of uploaded parts
+	ChunkRetries  map[int]int  // Retry count per part
+	IsCompleted   bool         // Upload completed
 }
 
 type StreamInput struct {
-	URL      string
-	Filename string
-	Size     int64
-	Headers  map[string]string
-	MIME     string
-	Duration int
-	Width    int
-	Height   int
-	Reader   io.ReadCloser
+	URL        string
+	Filename   string
+	Size       int64
+	Headers    map[string]string
+	MIME       string
+	Duration   int
+	Width      int
+	Height     int
+	Animated   bool
+	Title      string // Track title for audio documents
+	Performer  string // Artist or show name for audio documents
+	AsDocument bool   // Send as a plain file
+	AudioOnly  bool   // Audio was requested; ambiguous containers are audio
+	PublicOnly bool   // Only dial public addresses, for hosts chosen by users
+	Reader     io.ReadCloser
+	Media      *mediatype.Media // If set, filled from the first chunk before it is uploaded
+	Probe      *MediaProbe      // If set, filled from the first chunks by ProbeMedia
 }
 
 // Pipeline components
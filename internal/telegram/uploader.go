@@ -17,9 +17,11 @@ func NewUploader(api *tg.Client) *Uploader {
 }
 
 func (u *Uploader) UploadChunk(ctx context.Context, chunk streaming.Chunk, fileID int64, isBig bool) error {	
+	// -1 tells Telegram the part count is not known yet; the pipeline sets
+	// the real count on the last part of streams with unknown size.
 	totalParts := chunk.TotalParts
 	if totalParts <= 0 {
-		totalParts = 0 
+		totalParts = -1
 	}
 
 	if isBig {
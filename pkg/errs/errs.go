@@ -0,0 +1,229 @@
+// Package errs classifies failures into a few kinds that decide what the
+// user is told and whether trying again can help. Detail stays in the
+// wrapped error for logs and owner reports.
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Internal Kind = iota
+	Unsupported
+	NotFound
+	Private
+	TooLarge
+	RateLimited
+	UpstreamDown
+	Timeout
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Unsupported:
+		return "unsupported"
+	case NotFound:
+		return "not_found"
+	case Private:
+		return "private"
+	case TooLarge:
+		return "too_large"
+	case RateLimited:
+		return "rate_limited"
+	case UpstreamDown:
+		return "upstream_down"
+	case Timeout:
+		return "timeout"
+	default:
+		return "internal"
+	}
+}
+
+// Retryable reports whether the same request may succeed later.
+func (k Kind) Retryable() bool {
+	return k == RateLimited || k == UpstreamDown || k == Timeout
+}
+
+// Content reports whether the kind describes the requested content rather
+// than a fault in the bot or the upstream service.
+func (k Kind) Content() bool {
+	return k == Unsupported || k == NotFound || k == Private || k == TooLarge
+}
+
+// userMessages holds the text shown to users per kind and language.
+var userMessages = map[string]map[Kind]string{
+	"en": {
+		Internal:     "Something went wrong on our side, please try again.",
+		Unsupported:  "This link is not supported.",
+		NotFound:     "This content is unavailable or was removed.",
+		Private:      "This content is private, restricted or needs a login.",
+		TooLarge:     "This file is too large to send.",
+		RateLimited:  "The site is limiting requests right now, please try again in a few minutes.",
+		UpstreamDown: "The download service is temporarily unavailable, please try again later.",
+		Timeout:      "The request took too long, please try again.",
+	},
+	"id": {
+		Internal:     "Terjadi kesalahan di sisi kami, silakan coba lagi.",
+		Unsupported:  "Tautan ini tidak didukung.",
+		NotFound:     "Konten ini tidak tersedia atau sudah dihapus.",
+		Private:      "Konten ini privat, dibatasi, atau memerlukan login.",
+		TooLarge:     "File ini terlalu besar untuk dikirim.",
+		RateLimited:  "Situs sedang membatasi permintaan, silakan coba lagi beberapa menit lagi.",
+		UpstreamDown: "Layanan unduhan sedang tidak tersedia, silakan coba lagi nanti.",
+		Timeout:      "Permintaan memakan waktu terlalu lama, silakan coba lagi.",
+	},
+}
+
+// UserMessage returns the message for kind in the language of a Telegram
+// language code such as "en" or "id-ID", falling back to English.
+func (k Kind) UserMessage(langCode string) string {
+	lang := strings.ToLower(langCode)
+	if idx := strings.IndexAny(lang, "-_"); idx != -1 {
+		lang = lang[:idx]
+	}
+	messages, ok := userMessages[lang]
+	if !ok {
+		messages = userMessages["en"]
+	}
+	if msg, ok := messages[k]; ok {
+		return msg
+	}
+	return messages[Internal]
+}
+
+// Error is an error with a kind. Op names the failing operation for logs.
+type Error struct {
+	Kind Kind
+	Op   string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Op != "" && e.Err != nil:
+		return e.Op + ": " + e.Err.Error()
+	case e.Err != nil:
+		return e.Err.Error()
+	default:
+		return e.Op
+	}
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of kind with a fixed message, suitable as a sentinel
+// for errors.Is.
+func New(kind Kind, msg string) error {
+	return &Error{Kind: kind, Op: msg}
+}
+
+// Wrap attaches kind to err. It returns nil if err is nil.
+func Wrap(kind Kind, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Op: op, Err: err}
+}
+
+// Default wraps err with kind unless something in its chain already has a
+// kind, in which case that one is kept.
+func Default(kind Kind, op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return Wrap(kind, op, err)
+}
+
+// Errorf is fmt.Errorf with a kind.
+func Errorf(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// KindOf returns the kind of the outermost *Error in err's chain. Errors
+// without one are classified as timeouts or network failures when possible
+// and as Internal otherwise.
+func KindOf(err error) Kind {
+	if err == nil {
+		return Internal
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return networkKind(err)
+}
+
+// Is reports whether err is of kind.
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
+
+// Retryable reports whether err is worth retrying.
+func Retryable(err error) bool {
+	return err != nil && KindOf(err).Retryable()
+}
+
+// FromStatus maps an HTTP status code to a kind.
+func FromStatus(code int) Kind {
+	switch {
+	case code == http.StatusNotFound || code == http.StatusGone:
+		return NotFound
+	case code == http.StatusUnauthorized || code == http.StatusForbidden || code == http.StatusUnavailableForLegalReasons:
+		return Private
+	case code == http.StatusRequestEntityTooLarge:
+		return TooLarge
+	case code == http.StatusTooManyRequests:
+		return RateLimited
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+		return Timeout
+	case code >= 500:
+		return UpstreamDown
+	default:
+		return Internal
+	}
+}
+
+// Status returns an error of the kind matching an HTTP status code.
+func Status(op string, code int) error {
+	return &Error{Kind: FromStatus(code), Op: op, Err: fmt.Errorf("status %d", code)}
+}
+
+// Network wraps a transport error from an HTTP client or dialer.
+func Network(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: networkKind(err), Op: op, Err: err}
+}
+
+func networkKind(err error) Kind {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Timeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return Timeout
+		}
+		return UpstreamDown
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return UpstreamDown
+	}
+	return Internal
+}
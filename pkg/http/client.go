@@ -1,8 +1,14 @@
 package http
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"net/netip"
+	"syscall"
 	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
 )
 
 var botClient = &http.Client{
@@ -42,3 +48,30 @@ func GetBotClient() *http.Client {
 func GetDownloadClient() *http.Client {
 	return downloadClient
 }
+
+// PublicOnly is a net.Dialer Control that refuses loopback, link-local and
+// private addresses, so hosts picked by users cannot reach the bot's own
+// network. It runs on the resolved address of every dial, redirects
+// included.
+func PublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() {
+		return errs.Errorf(errs.Unsupported, "%s is not a public address", ip)
+	}
+	return nil
+}
+
+// PublicDialContext dials like net.Dialer with the given timeout, guarded
+// by PublicOnly.
+func PublicDialContext(timeout time.Duration) func(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout, Control: PublicOnly}
+	return dialer.DialContext
+}
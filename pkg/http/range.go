@@ -6,6 +6,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
 )
 
 const (
@@ -24,7 +26,7 @@ type ChunkedReader struct {
 	err        error
 }
 
-func NewChunkedReader(ctx context.Context, url string, headers map[string]string, totalSize int64) *ChunkedReader {
+func NewChunkedReader(ctx context.Context, url string, headers map[string]string, totalSize int64, publicOnly bool) *ChunkedReader {
 	transport := &http.Transport{
 		MaxIdleConns:        100,
 		MaxIdleConnsPerHost: 20,
@@ -32,6 +34,9 @@ func NewChunkedReader(ctx context.Context, url string, headers map[string]string
 		TLSHandshakeTimeout: 10 * time.Second,
 		ResponseHeaderTimeout: 30 * time.Second,
 	}
+	if publicOnly {
+		transport.DialContext = PublicDialContext(DefaultTimeout)
+	}
 
 	return &ChunkedReader{
 		ctx:       ctx,
@@ -103,12 +108,12 @@ func (r *ChunkedReader) nextChunk() error {
 
 	resp, err := r.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("range request failed: %w", err)
+		return errs.Network("range request failed", err)
 	}
 
 	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return errs.Status("range request", resp.StatusCode)
 	}
 
 	r.currentBody = resp.Body
@@ -6,6 +6,8 @@ import (
 	"io"
 	"net/http"
 	"time"
+
+	"github.com/pavelc4/aether-tg-bot/pkg/errs"
 )
 
 const (
@@ -13,7 +15,9 @@ const (
 )
 
 
-func StreamRequest(ctx context.Context, url string, headers map[string]string) (io.ReadCloser, int64, string, error) {
+// StreamRequest opens url for chunked download. With publicOnly set, only
+// public addresses are dialed; use it for hosts chosen by users.
+func StreamRequest(ctx context.Context, url string, headers map[string]string, publicOnly bool) (io.ReadCloser, int64, string, error) {
 	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
 	if err != nil {
 		return nil, 0, "", fmt.Errorf("create head request failed: %w", err)
@@ -33,21 +37,24 @@ func StreamRequest(ctx context.Context, url string, headers map[string]string) (
 		TLSHandshakeTimeout: 10 * time.Second,
 		ResponseHeaderTimeout: 30 * time.Second,
 	}
+	if publicOnly {
+		transport.DialContext = PublicDialContext(DefaultTimeout)
+	}
 	client := &http.Client{Transport: transport}
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, 0, "", fmt.Errorf("head request failed: %w", err)
+		return nil, 0, "", errs.Network("head request failed", err)
 	}
 	resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, 0, "", fmt.Errorf("head http error: %s", resp.Status)
+		return nil, 0, "", errs.Status("head request", resp.StatusCode)
 	}
 
 	size := resp.ContentLength
 	contentType := resp.Header.Get("Content-Type")
 
-	reader := NewChunkedReader(ctx, url, headers, size)
+	reader := NewChunkedReader(ctx, url, headers, size, publicOnly)
 	return reader, size, contentType, nil
 }
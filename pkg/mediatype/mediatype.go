@@ -0,0 +1,173 @@
+// Package mediatype decides how a file is sent to Telegram. The first bytes
+// of the stream are authoritative; declared MIME types and file extensions
+// are only used when the content has no known signature.
+package mediatype
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// MaxSmallFile is the largest file Telegram accepts through
+// upload.saveFilePart, and the largest photo it accepts.
+const MaxSmallFile = 10 * 1024 * 1024
+
+type Kind int
+
+const (
+	Document Kind = iota
+	Photo
+	Animation
+	Video
+	Audio
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Photo:
+		return "photo"
+	case Animation:
+		return "animation"
+	case Video:
+		return "video"
+	case Audio:
+		return "audio"
+	default:
+		return "document"
+	}
+}
+
+// Media is the classification of one file.
+type Media struct {
+	Kind Kind
+	MIME string
+	Big  bool // Upload with saveBigFilePart
+}
+
+var extensions = map[string]string{
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".mkv":  "video/x-matroska",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".ogg":  "audio/ogg",
+	".wav":  "audio/wav",
+	".opus": "audio/opus",
+	".flac": "audio/flac",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+	".gif":  "image/gif",
+}
+
+// ByExtension returns the MIME type for the extension of name, or "" if it
+// is not a known media extension. Query strings are ignored.
+func ByExtension(name string) string {
+	if idx := strings.IndexAny(name, "?#"); idx != -1 {
+		name = name[:idx]
+	}
+	return extensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// Sniff returns the MIME type matching the signature at the start of head,
+// or "" if none matches.
+func Sniff(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return "image/gif"
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WEBP":
+		return "image/webp"
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")) && string(head[8:12]) == "WAVE":
+		return "audio/wav"
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		return isoBrandMIME(string(head[8:12]))
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// The DocType string sits in the EBML header at the very start
+		end := len(head)
+		if end > 64 {
+			end = 64
+		}
+		if bytes.Contains(head[:end], []byte("webm")) {
+			return "video/webm"
+		}
+		return "video/x-matroska"
+	case bytes.HasPrefix(head, []byte("OggS")):
+		return "audio/ogg"
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return "audio/mpeg"
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0 && head[1]&0x06 != 0:
+		// MPEG audio frame sync with a valid layer; ADTS AAC has layer 0
+		return "audio/mpeg"
+	}
+	return ""
+}
+
+// isoBrandMIME maps the major brand of an ISO-BMFF file to a MIME type.
+func isoBrandMIME(brand string) string {
+	switch brand {
+	case "M4A ", "M4B ", "M4P ", "F4A ", "F4B ":
+		return "audio/mp4"
+	case "qt  ":
+		return "video/quicktime"
+	}
+	return "video/mp4"
+}
+
+// Classify decides how a file is sent from the first chunk of its stream,
+// the declared MIME type and file name, and its size (0 if unknown).
+// Containers that may hold only audio, such as MP4 with a generic brand,
+// follow the declared type or audioOnly.
+func Classify(head []byte, declared, filename string, size int64, asDocument, audioOnly bool) Media {
+	if declared == "" || declared == "application/octet-stream" {
+		if byExt := ByExtension(filename); byExt != "" {
+			declared = byExt
+		}
+	}
+
+	mime := Sniff(head)
+	switch {
+	case mime == "":
+		mime = declared
+	case strings.HasPrefix(declared, "audio/") || audioOnly:
+		switch mime {
+		case "video/mp4", "video/quicktime":
+			mime = "audio/mp4"
+		case "video/webm", "video/x-matroska":
+			mime = "audio/webm"
+		}
+	}
+	if mime == "" {
+		mime = "application/octet-stream"
+	}
+
+	m := Media{Kind: Document, MIME: mime}
+	if !asDocument {
+		switch {
+		case mime == "image/gif":
+			m.Kind = Animation
+		case strings.HasPrefix(mime, "image/"):
+			// Photos must be small files, which needs the size up front
+			if size > 0 && size <= MaxSmallFile {
+				m.Kind = Photo
+			}
+		case strings.HasPrefix(mime, "video/"):
+			m.Kind = Video
+		case strings.HasPrefix(mime, "audio/"):
+			m.Kind = Audio
+		}
+	}
+
+	// Streams of unknown size (muxed, piped) are uploaded as big files,
+	// except photos, which Telegram only takes as small ones
+	m.Big = m.Kind != Photo && (size <= 0 || size > MaxSmallFile)
+	return m
+}